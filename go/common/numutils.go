@@ -2,6 +2,7 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -11,6 +12,7 @@ type IntRange struct {
 	Start   *int
 	End     *int
 	Indices []int
+	Names   []string //the column names, if any, at the same position as Indices. resolved by Resolve
 	Exclude bool
 }
 
@@ -19,10 +21,64 @@ func (r *IntRange) IntRangeClone() *IntRange {
 		Start:   r.Start,
 		End:     r.End,
 		Indices: r.Indices,
+		Names:   r.Names,
 		Exclude: r.Exclude,
 	}
 }
 
+func (r *IntRange) HasNames() bool {
+	return len(r.Names) > 0
+}
+
+// Resolve converts the column names into indices based on the headers
+func (r *IntRange) Resolve(headers []string) error {
+	if !r.HasNames() {
+		return nil
+	}
+	indices := make([]int, len(r.Indices))
+	copy(indices, r.Indices)
+	for i, name := range r.Names {
+		if name == "" {
+			continue
+		}
+		index := IndexOf(headers, name)
+		if index < 0 {
+			return UnknownColumnError(name, headers)
+		}
+		indices[i] = index
+	}
+	r.Indices = indices
+	r.Names = nil
+	return nil
+}
+
+func IndexOf(array []string, str string) int {
+	for i, item := range array {
+		if item == str {
+			return i
+		}
+	}
+	return -1
+}
+
+// UnknownColumnError has the closest column name, if any, as the hint
+func UnknownColumnError(name string, headers []string) error {
+	if match := ClosestMatch(name, headers); match != "" {
+		return fmt.Errorf("unknown column '%v', did you mean '%v'? The columns are %v", name, match, headers)
+	}
+	return fmt.Errorf("unknown column '%v'. The columns are %v", name, headers)
+}
+
+// IsColumnName returns true if the str is not made of indices or ranges
+func IsColumnName(str string) bool {
+	for _, char := range str {
+		if !unicode.IsDigit(char) && !unicode.IsSpace(char) && !strings.ContainsRune("-:,", char) {
+			return true
+		}
+	}
+	return false
+}
+
 /*
 	[1]
 	[1,2]
@@ -32,6 +88,8 @@ func (r *IntRange) IntRangeClone() *IntRange {
 	[-1-3] == (-1) -> (+3)
 	[-10--3] == (-10) -> (-3)
 	[1,2,5:,6:]
+	[NAME,STATUS] => column names, resolved against the headers
+	[NAME,2]
 */
 func ParseRange(str string) *IntRange {
 	num, err := strconv.Atoi(str)
@@ -39,6 +97,9 @@ func ParseRange(str string) *IntRange {
 		return &IntRange{Indices: []int{num}}
 	}
 	var vals []int
+	if IsColumnName(str) {
+		return parseNamedRange(str)
+	}
 	if strings.Index(str, ":") != -1 {
 		split := strings.Split(str, ":")
 		if len(split) == 1 {
@@ -63,6 +124,72 @@ func ParseRange(str string) *IntRange {
 	}
 }
 
+/*
+	InvalidRangePos returns the position of the first invalid char of an index, -1 if the index is valid
+	10, -1, 10:20, 10:, :20, 10-12, -10--3 => valid
+	10:20:30 => 5, 10- => 3, 1x => 1
+*/
+func InvalidRangePos(str string) int {
+	i := 0
+	readNum := func(required bool) bool {
+		if i < len(str) && str[i] == '-' {
+			i++
+		} else if !required && (i >= len(str) || !isDigitByte(str[i])) {
+			return true
+		}
+		start := i
+		for i < len(str) && isDigitByte(str[i]) {
+			i++
+		}
+		return i > start
+	}
+	if strings.HasPrefix(str, ":") {
+		i++
+		if !readNum(false) {
+			return i
+		}
+	} else {
+		if !readNum(true) {
+			return i
+		}
+		if i < len(str) && str[i] == ':' {
+			i++
+			if !readNum(false) {
+				return i
+			}
+		} else if i < len(str) && str[i] == '-' {
+			i++
+			if !readNum(true) {
+				return i
+			}
+		}
+	}
+	if i < len(str) {
+		return i
+	}
+	return -1
+}
+
+func isDigitByte(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func parseNamedRange(str string) *IntRange {
+	r := &IntRange{}
+	for _, part := range strings.Split(str, ",") {
+		part = strings.TrimSpace(part)
+		if IsColumnName(part) {
+			r.Indices = append(r.Indices, -1)
+			r.Names = append(r.Names, part)
+			continue
+		}
+		indices := ParseRange(part).Indices
+		r.Indices = append(r.Indices, indices...)
+		r.Names = append(r.Names, make([]string, len(indices))...)
+	}
+	return r
+}
+
 func ResolveBounds(bounds []int) []int {
 	if len(bounds) == 1 {
 		return bounds
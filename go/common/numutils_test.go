@@ -29,6 +29,42 @@ func TestStartEnd(t *testing.T) {
 	assertRangeEquals(t, ParseRange(":20"), nil, P(20), nil)
 }
 
+func TestNames(t *testing.T) {
+	r := ParseRange("NAME,2,STATUS")
+	AssertIntArray(t, r.Indices, []int{-1, 2, -1})
+	if !r.HasNames() {
+		t.Fatalf("Expected the names to be parsed %+v", r)
+	}
+	if err := r.Resolve([]string{"NAMESPACE", "NAME", "READY", "STATUS"}); err != nil {
+		t.Fatal(err)
+	}
+	AssertIntArray(t, r.Indices, []int{1, 2, 3})
+
+	r = ParseRange("CURRENT-OFFSET")
+	if err := r.Resolve([]string{"TOPIC", "CURRENT-OFFSET"}); err != nil {
+		t.Fatal(err)
+	}
+	AssertIntArray(t, r.Indices, []int{1})
+
+	if err := ParseRange("AGE").Resolve([]string{"NAME"}); err == nil {
+		t.Fatalf("Expected an error for the unknown column")
+	}
+	AssertIntArray(t, ParseRange("10-12").Indices, []int{10, 11})
+}
+
+func TestInvalidRangePos(t *testing.T) {
+	var positions []int
+	for _, str := range []string{"10", "-1", "10:20", "10:", ":20", ":", "10-12", "-10--3", "10--12"} {
+		positions = append(positions, InvalidRangePos(str))
+	}
+	AssertIntArray(t, positions, []int{-1, -1, -1, -1, -1, -1, -1, -1, -1})
+	positions = nil
+	for _, str := range []string{"10:20:30", "10-", "1x", "", "-", "1:-", "--1", "1 2"} {
+		positions = append(positions, InvalidRangePos(str))
+	}
+	AssertIntArray(t, positions, []int{5, 3, 1, 0, 1, 3, 1, 1})
+}
+
 func P(int2 int) *int {
 	return &int2
 }
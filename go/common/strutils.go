@@ -311,3 +311,101 @@ func DelBlankItems(s []string) []string {
 	}
 	return r
 }
+
+/*
+	SplitArgs splits the string into the args similar to the shell. The quotes are removed
+	row[1:] 'filter..[2] > 100' out..table => [row[1:], filter..[2] > 100, out..table]
+*/
+func SplitArgs(str string) []string {
+	var args []string
+	var sb strings.Builder
+	var quote rune
+	inArg := false
+	escaped := false
+	for _, char := range str {
+		switch {
+		case escaped:
+			sb.WriteRune(char)
+			escaped = false
+		case char == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			} else {
+				sb.WriteRune(char)
+			}
+		case char == '\'' || char == '"':
+			quote = char
+			inArg = true
+		case char == ' ' || char == '\t' || char == '\n':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteRune(char)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args
+}
+
+// EditDistance is the number of the single char edits to change one string into the other
+func EditDistance(one string, two string) int {
+	a := []rune(one)
+	b := []rune(two)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(vals ...int) int {
+	min := vals[0]
+	for _, val := range vals[1:] {
+		if val < min {
+			min = val
+		}
+	}
+	return min
+}
+
+/*
+	ClosestMatch returns the candidate nearest to the name for the "did you mean" hints, empty if none of the
+	candidates is close enough. The case is ignored eg. sorrt => sort, lagg => LAG
+*/
+func ClosestMatch(name string, candidates []string) string {
+	lower := strings.ToLower(name)
+	maxDistance := 2
+	if len(lower) <= 3 {
+		maxDistance = 1
+	}
+	match := ""
+	for _, candidate := range candidates {
+		distance := EditDistance(lower, strings.ToLower(candidate))
+		if distance <= maxDistance && distance < len(lower) {
+			match = candidate
+			maxDistance = distance - 1
+		}
+	}
+	return match
+}
@@ -21,3 +21,28 @@ func AssertStrArray(t *testing.T, actual []string, expected []string) {
 		}
 	}
 }
+
+func TestSplitArgs(t *testing.T) {
+	AssertStrArray(t, SplitArgs("row[1:] group[0]:count  sort[1]:desc out..table"),
+		[]string{"row[1:]", "group[0]:count", "sort[1]:desc", "out..table"})
+	AssertStrArray(t, SplitArgs(`'filter..[2] > 100' "calc([0]+\"/\"+[1])"`),
+		[]string{"filter..[2] > 100", `calc([0]+"/"+[1])`})
+	AssertStrArray(t, SplitArgs(`'regex_extract([0],"v(\\d+)")' a\ b ''`),
+		[]string{`regex_extract([0],"v(\\d+)")`, "a b", ""})
+	AssertStrArray(t, SplitArgs(""), []string{})
+}
+
+func TestClosestMatch(t *testing.T) {
+	if EditDistance("sorrt", "sort") != 1 || EditDistance("grop", "group") != 1 || EditDistance("", "col") != 3 {
+		t.Fatalf("Invalid edit distance")
+	}
+	flags := []string{"row", "col", "ncol", "sort", "group", "tail", "top"}
+	AssertStrArray(t, []string{
+		ClosestMatch("sorrt", flags),
+		ClosestMatch("grop", flags),
+		ClosestMatch("cols", flags),
+		ClosestMatch("lagg", []string{"TOPIC", "LAG"}),
+		ClosestMatch("xyz", flags),
+		ClosestMatch("c", []string{"a", "b"}),
+	}, []string{"sort", "group", "col", "LAG", "", ""})
+}
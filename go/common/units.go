@@ -0,0 +1,139 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationRegex = regexp.MustCompile(`(\d+\w)+?`)
+
+var ageRegex = regexp.MustCompile(`^(\d+[smhdwy])+$`)
+
+var bytesRegex = regexp.MustCompile(`^([+-]?[0-9]*\.?[0-9]+)\s*([a-zA-Z]*)$`)
+
+/*
+	ParseDuration parses the go durations eg. 1h30m, 500ms and the kubectl ages eg. 5d3h, 2y10d
+*/
+func ParseDuration(durStr string) *time.Duration {
+	if dur, err := time.ParseDuration(strings.TrimSpace(durStr)); err == nil {
+		return &dur
+	}
+	strs := durationRegex.FindAllString(durStr, -1)
+	tot := time.Duration(0)
+	for _, str := range strs {
+		val, _ := strconv.Atoi(str[:len(str)-1])
+		suffix := str[len(str)-1:]
+		switch suffix {
+		case "s":
+			tot += time.Second * time.Duration(val)
+		case "m":
+			tot += time.Minute * time.Duration(val)
+		case "h":
+			tot += time.Hour * time.Duration(val)
+		case "d":
+			tot += time.Hour * 24 * time.Duration(val)
+		case "w":
+			tot += time.Hour * 24 * 7 * time.Duration(val)
+		case "y":
+			tot += time.Hour * 24 * 365 * time.Duration(val)
+		}
+	}
+	return &tot
+}
+
+// IsDuration returns true for the durations eg. 2d, 1h30m
+func IsDuration(str string) bool {
+	str = strings.TrimSpace(str)
+	if _, err := strconv.ParseFloat(str, 64); err == nil {
+		return false
+	}
+	if _, err := time.ParseDuration(str); err == nil {
+		return true
+	}
+	return ageRegex.MatchString(str)
+}
+
+/*
+	ParseBytes parses the sizes with the SI or the binary units
+	512Mi => 512 * 1024 * 1024
+	1.5G  => 1.5 * 1000 * 1000 * 1000
+	100KB => 100 * 1000
+*/
+func ParseBytes(str string) (float64, error) {
+	matches := bytesRegex.FindStringSubmatch(strings.TrimSpace(str))
+	if matches == nil {
+		return 0, fmt.Errorf("invalid size '%v'", str)
+	}
+	val, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%v'", str)
+	}
+	unit := strings.ToLower(strings.TrimSuffix(strings.TrimSuffix(matches[2], "B"), "b"))
+	if unit == "" {
+		return val, nil
+	}
+	base := 1000.0
+	if strings.HasSuffix(unit, "i") {
+		base = 1024.0
+		unit = strings.TrimSuffix(unit, "i")
+	}
+	exp := strings.Index("kmgtpe", unit)
+	if len(unit) != 1 || exp < 0 {
+		return 0, fmt.Errorf("invalid size unit '%v' in '%v'", matches[2], str)
+	}
+	for i := 0; i <= exp; i++ {
+		val = val * base
+	}
+	return val, nil
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"02/Jan/2006:15:04:05 -0700",
+	time.RFC1123Z,
+	time.RFC1123,
+	time.UnixDate,
+	time.ANSIC,
+}
+
+/*
+	ParseTime parses the common timestamp formats and the unix epoch in seconds or millis
+	2021-06-01T10:30:00Z, 2021-06-01 10:30:00  => ISO8601
+	2021/06/01 10:30:00                        => the go log
+	01/Jun/2021:10:30:00 +0000                 => the common log format of apache, nginx
+	1622543400, 1622543400.25, 1622543400000   => the epoch
+*/
+func ParseTime(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			return t, nil
+		}
+	}
+	if epoch, err := strconv.ParseInt(str, 10, 64); err == nil {
+		if epoch > 1e11 {
+			return time.UnixMilli(epoch), nil
+		}
+		return time.Unix(epoch, 0), nil
+	}
+	if epoch, err := strconv.ParseFloat(str, 64); err == nil && !strings.ContainsAny(str, "eE") {
+		if epoch > 1e11 {
+			epoch = epoch / 1000
+		}
+		sec, frac := math.Modf(epoch)
+		return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1000), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%v'", str)
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	assertDuration(t, "45s", 45*time.Second)
+	assertDuration(t, "2m30s", 150*time.Second)
+	assertDuration(t, "5d3h", 123*time.Hour)
+	assertDuration(t, "500ms", 500*time.Millisecond)
+	assertDuration(t, "1.5h", 90*time.Minute)
+	assertDuration(t, "2y10d", (365*2+10)*24*time.Hour)
+	if !IsDuration("2d") || !IsDuration("1h30m") || IsDuration("2x") || IsDuration("abc") {
+		t.Fatalf("IsDuration mismatch")
+	}
+}
+
+func assertDuration(t *testing.T, str string, expected time.Duration) {
+	if actual := *ParseDuration(str); actual != expected {
+		t.Fatalf("Duration Mismatch for %v Actual=%v, Expected: %v", str, actual, expected)
+	}
+}
+
+func TestParseBytes(t *testing.T) {
+	assertBytes(t, "512Mi", 512*1024*1024)
+	assertBytes(t, "1.5G", 1.5e9)
+	assertBytes(t, "100KB", 100000)
+	assertBytes(t, "2KiB", 2048)
+	assertBytes(t, "42", 42)
+	assertBytes(t, "10 Gi", 10*1024*1024*1024)
+	if _, err := ParseBytes("10Zi"); err == nil {
+		t.Fatalf("Expected an error for the invalid unit")
+	}
+	if _, err := ParseBytes("abc"); err == nil {
+		t.Fatalf("Expected an error for the invalid size")
+	}
+}
+
+func assertBytes(t *testing.T, str string, expected float64) {
+	actual, err := ParseBytes(str)
+	if err != nil || actual != expected {
+		t.Fatalf("Bytes Mismatch for %v Actual=%v, Expected: %v, Error: %v", str, actual, expected, err)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	expected := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+	for _, str := range []string{"2021-06-01T10:30:00Z", "2021-06-01 10:30:00", "1622543400", "1622543400000",
+		"1622543400.0", "2021/06/01 10:30:00", "01/Jun/2021:12:30:00 +0200"} {
+		actual, err := ParseTime(str)
+		if err != nil || !actual.Equal(expected) {
+			t.Fatalf("Time Mismatch for %v Actual=%v, Expected: %v, Error: %v", str, actual, expected, err)
+		}
+	}
+	actual, err := ParseTime("1622543400.25")
+	if err != nil || !actual.Equal(expected.Add(250*time.Millisecond)) {
+		t.Fatalf("Time Mismatch for the fractional epoch Actual=%v, Error: %v", actual, err)
+	}
+	if _, err := ParseTime("yesterday"); err == nil {
+		t.Fatalf("Expected an error for the invalid time")
+	}
+}
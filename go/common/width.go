@@ -0,0 +1,135 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type runeRange struct {
+	first rune
+	last  rune
+}
+
+// the east asian wide and fullwidth chars and the emojis, two columns in the terminal
+var wideRanges = []runeRange{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC}, {0x23F0, 0x23F0}, {0x23F3, 0x23F3},
+	{0x25FD, 0x25FE}, {0x2614, 0x2615}, {0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE}, {0x26D4, 0x26D4}, {0x26EA, 0x26EA},
+	{0x26F2, 0x26F3}, {0x26F5, 0x26F5}, {0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755}, {0x2757, 0x2757}, {0x2795, 0x2797},
+	{0x27B0, 0x27B0}, {0x27BF, 0x27BF}, {0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF}, {0xA960, 0xA97F}, {0xAC00, 0xD7A3},
+	{0xF900, 0xFAFF}, {0xFE10, 0xFE19}, {0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18CFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF}, {0x1F18E, 0x1F18E},
+	{0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F300, 0x1F64F}, {0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB},
+	{0x1F90C, 0x1F9FF}, {0x1FA70, 0x1FAFF}, {0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+/*
+	RuneWidth returns the number of the columns of the rune in the terminal. 2 for the wide chars eg. CJK, emoji, 0
+	for the control chars, the combining marks and the zero width chars eg. the variation selectors, 1 otherwise
+*/
+func RuneWidth(r rune) int {
+	if r < 0x20 || (r >= 0x7F && r < 0xA0) {
+		return 0
+	}
+	if r < 0x300 {
+		return 1
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || (r >= 0x1160 && r <= 0x11FF) {
+		return 0
+	}
+	i := sort.Search(len(wideRanges), func(i int) bool {
+		return wideRanges[i].last >= r
+	})
+	if i < len(wideRanges) && wideRanges[i].first <= r {
+		return 2
+	}
+	return 1
+}
+
+// DisplayWidth returns the number of the columns of the str in the terminal
+func DisplayWidth(str string) int {
+	width := 0
+	for _, r := range str {
+		width += RuneWidth(r)
+	}
+	return width
+}
+
+// TruncateWidth truncates the str with an ellipsis if it is wider than the width
+func TruncateWidth(str string, width int) string {
+	if DisplayWidth(str) <= width {
+		return str
+	}
+	if width <= 0 {
+		return ""
+	}
+	var sb strings.Builder
+	used := 0
+	for _, r := range str {
+		w := RuneWidth(r)
+		if used+w > width-1 {
+			break
+		}
+		sb.WriteRune(r)
+		used += w
+	}
+	sb.WriteString("…")
+	return sb.String()
+}
+
+// PadWidth pads the str with the spaces to the width, on the left if alignRight
+func PadWidth(str string, width int, alignRight bool) string {
+	pad := width - DisplayWidth(str)
+	if pad <= 0 {
+		return str
+	}
+	if alignRight {
+		return strings.Repeat(" ", pad) + str
+	}
+	return str + strings.Repeat(" ", pad)
+}
+
+// WrapWidth splits the str into the lines not wider than the width. The lines are split at the spaces if possible
+func WrapWidth(str string, width int) []string {
+	if width <= 0 || DisplayWidth(str) <= width {
+		return []string{str}
+	}
+	var lines []string
+	var line []rune
+	lineWidth := 0
+	for _, r := range str {
+		w := RuneWidth(r)
+		if lineWidth+w > width && len(line) > 0 {
+			space := lastSpace(line)
+			if space > 0 {
+				lines = append(lines, string(line[:space]))
+				line = append([]rune{}, line[space+1:]...)
+			} else {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lineWidth = DisplayWidth(string(line))
+		}
+		if len(line) == 0 && r == ' ' {
+			continue
+		}
+		line = append(line, r)
+		lineWidth += w
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,44 @@
+package common
+
+import "testing"
+
+func TestDisplayWidth(t *testing.T) {
+	for str, expected := range map[string]int{
+		"abc":        3,
+		"日本語":        6,
+		"한국":         4,
+		"café":       4,
+		"cafe\u0301": 4,
+		"🚀 ok":       5,
+		"┌─┐":        3,
+		"▁▃█":        3,
+		"ｆｕｌｌ":       8,
+		"":           0,
+	} {
+		if actual := DisplayWidth(str); actual != expected {
+			t.Fatalf("Width Mismatch for %q Actual=%v, Expected: %v", str, actual, expected)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	assertWidthStr(t, TruncateWidth("abcdef", 6), "abcdef")
+	assertWidthStr(t, TruncateWidth("abcdef", 4), "abc…")
+	assertWidthStr(t, TruncateWidth("日本語テキスト", 6), "日本…")
+	assertWidthStr(t, TruncateWidth("日本語", 1), "…")
+	assertWidthStr(t, PadWidth("日本", 6, false), "日本  ")
+	assertWidthStr(t, PadWidth("42", 5, true), "   42")
+}
+
+func TestWrapWidth(t *testing.T) {
+	AssertStrArray(t, WrapWidth("the quick brown fox", 10), []string{"the quick", "brown fox"})
+	AssertStrArray(t, WrapWidth("abcdefghij", 4), []string{"abcd", "efgh", "ij"})
+	AssertStrArray(t, WrapWidth("日本語テキスト", 6), []string{"日本語", "テキス", "ト"})
+	AssertStrArray(t, WrapWidth("short", 10), []string{"short"})
+}
+
+func assertWidthStr(t *testing.T, actual string, expected string) {
+	if actual != expected {
+		t.Fatalf("String Mismatch Actual=%q, Expected: %q", actual, expected)
+	}
+}
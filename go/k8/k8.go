@@ -5,13 +5,13 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"github.com/abeytom/utilbox/common"
 	"github.com/abeytom/utilbox/utils"
 	"gopkg.in/yaml.v2"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -300,24 +300,7 @@ func getPodByName(namespace string, podMatch string, matchIndex int, runningOnly
 }
 
 func ParseDuration(durStr string) *time.Duration {
-	re := regexp.MustCompile(`(\d+\w)+?`)
-	strs := re.FindAllString(durStr, -1)
-	tot := time.Duration(0)
-	for _, str := range strs {
-		val, _ := strconv.Atoi(str[:len(str)-1])
-		suffix := str[len(str)-1:]
-		switch suffix {
-		case "s":
-			tot += time.Second * time.Duration(val)
-		case "m":
-			tot += time.Minute * time.Duration(val)
-		case "h":
-			tot += time.Hour * time.Duration(val)
-		case "d":
-			tot += time.Hour * 24 * time.Duration(val)
-		}
-	}
-	return &tot
+	return common.ParseDuration(durStr)
 }
 
 func ExecuteCommand(cmdName string, args ...string) (string, string, error) {
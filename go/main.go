@@ -30,6 +30,8 @@ func main() {
 		utils.JsonParse(args[2:])
 	} else if args[1] == "json_parse_line" {
 		utils.JsonParseLine(args[2:])
+	} else if args[1] == "logfmt_parse_line" {
+		utils.LogfmtParseLine(args[2:])
 	} else if args[1] == "csv_parse" {
 		utils.CsvParse(args[2:])
 	} else if args[1] == "yaml_parse" {
@@ -0,0 +1,211 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"github.com/abeytom/utilbox/utils"
+	"io"
+	"strings"
+)
+
+/*
+	The pipeline runs the transforms of the csv, jp and yp commands with a reader and a writer. The errors are
+	returned instead of exiting
+
+	p := pipeline.Pipeline{
+		Source: pipeline.TextSource(reader),
+		Stages: []pipeline.Stage{
+			pipeline.Filter{Expr: "[LAG] > 0"},
+			pipeline.Group{Cols: "TOPIC", Aggs: "LAG=sum", Count: true},
+			pipeline.Sort{Cols: "LAG", Desc: true},
+			pipeline.Limit(10),
+		},
+		Sink: pipeline.Sink{Writer: writer, Format: pipeline.Table},
+	}
+	err := p.Run()
+*/
+type Pipeline struct {
+	Source Source
+	Stages []Stage
+	Sink   Sink
+}
+
+// the types of the sources and the formats of the sinks
+const (
+	Text     = "text"
+	Csv      = "csv"
+	Columns  = "columns"
+	Json     = "json"
+	Yaml     = "yaml"
+	Jsonl    = "jsonl"
+	Table    = "table"
+	Tsv      = "tsv"
+	Markdown = "md"
+	Html     = "html"
+	Kv       = "kv"
+)
+
+/*
+	Source reads the rows. The Text is split by the whitespace or by the Delim, the Delim is the separator of the Csv.
+	The Columns are the aligned columns eg. kubectl get pods. The Keys select the values of the Json and the Yaml,
+	the keys are listed if there are none
+*/
+type Source struct {
+	Reader   io.Reader
+	Type     string
+	Delim    string
+	Keys     []string
+	NoHeader bool
+}
+
+// Sink writes the output. The empty Format is the default of the command, csv for the text and table for the json
+type Sink struct {
+	Writer   io.Writer
+	Format   string
+	NoHeader bool
+}
+
+func TextSource(reader io.Reader) Source {
+	return Source{Reader: reader, Type: Text}
+}
+
+func CsvSource(reader io.Reader) Source {
+	return Source{Reader: reader, Type: Csv}
+}
+
+func ColumnsSource(reader io.Reader) Source {
+	return Source{Reader: reader, Type: Columns}
+}
+
+func JsonSource(reader io.Reader, keys ...string) Source {
+	return Source{Reader: reader, Type: Json, Keys: keys}
+}
+
+func YamlSource(reader io.Reader, keys ...string) Source {
+	return Source{Reader: reader, Type: Yaml, Keys: keys}
+}
+
+func (s Source) args() ([]string, error) {
+	var args []string
+	switch s.Type {
+	case Text, "":
+		if s.Delim != "" {
+			args = append(args, "split:"+s.Delim)
+		}
+	case Csv:
+		if s.Delim != "" {
+			args = append(args, "split:csv..sep:"+s.Delim)
+		} else {
+			args = append(args, "split:csv")
+		}
+	case Columns:
+		args = append(args, "split:"+s.Type)
+	case Json, Yaml:
+		if len(s.Keys) == 0 {
+			args = append(args, "keys")
+		} else {
+			args = append(args, "keys["+strings.Join(s.Keys, ",")+"]")
+		}
+	default:
+		return nil, fmt.Errorf("Unknown source type '%v'. The types are text, csv, columns, json and yaml", s.Type)
+	}
+	if s.NoHeader {
+		args = append(args, "-inhead")
+	}
+	return args, nil
+}
+
+func (s Sink) args() []string {
+	var args []string
+	if s.Format != "" {
+		args = append(args, "out.."+s.Format)
+	}
+	if s.NoHeader {
+		args = append(args, "-outhead")
+	}
+	return args
+}
+
+// Args returns the flags of the csv command for the source, the stages and the sink
+func (p *Pipeline) Args() ([]string, error) {
+	if err := checkOrder(p.Stages); err != nil {
+		return nil, err
+	}
+	args, err := p.Source.args()
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range p.Stages {
+		if err := checkFields(stage); err != nil {
+			return nil, err
+		}
+		args = append(args, stage.Args()...)
+	}
+	return append(args, p.Sink.args()...), nil
+}
+
+// Format builds the format of the command from the flags. A format is used for a single run
+func (p *Pipeline) Format() (*utils.CsvFormat, error) {
+	args, err := p.Args()
+	if err != nil {
+		return nil, err
+	}
+	var csvFmt *utils.CsvFormat
+	if p.isJson() {
+		csvFmt, err = utils.ParseJsonArgs(args)
+	} else {
+		csvFmt, err = utils.ParseCsvArgs(args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	csvFmt.Writer = p.Sink.Writer
+	return csvFmt, nil
+}
+
+// Run reads the source, applies the stages and writes the output to the sink
+func (p *Pipeline) Run() error {
+	if p.Source.Reader == nil {
+		return errors.New("The source has no reader")
+	}
+	if p.Sink.Writer == nil {
+		return errors.New("The sink has no writer")
+	}
+	csvFmt, err := p.Format()
+	if err != nil {
+		return err
+	}
+	writer := &errWriter{writer: p.Sink.Writer}
+	csvFmt.Writer = writer
+	switch p.Source.Type {
+	case Json:
+		err = utils.ProcessJson(csvFmt, p.Source.Reader)
+	case Yaml:
+		err = utils.ProcessYaml(csvFmt, p.Source.Reader)
+	default:
+		err = utils.ProcessCsv(csvFmt, p.Source.Reader)
+	}
+	if err != nil {
+		return err
+	}
+	return writer.err
+}
+
+func (p *Pipeline) isJson() bool {
+	return p.Source.Type == Json || p.Source.Type == Yaml
+}
+
+// errWriter retains the first error of the writer, the rest of the writes are skipped
+type errWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func (w *errWriter) Write(bytes []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.writer.Write(bytes)
+	w.err = err
+	return n, err
+}
@@ -0,0 +1,296 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Stage is a transform of the pipeline. The Args are the flags of the csv command. The stages are applied in a fixed
+	order, see stageOrder, the pipeline fails if the stages are in a different order. The columns are the indices or
+	the header names eg. "0,2", "1:", "TOPIC,LAG"
+
+	The fields are joined into the flags and parsed by the csv command, so a field cannot have the delimiter of its
+	flag eg. ".." in the Filter.Expr, "]" in the Group.Cols. See checkFields
+*/
+type Stage interface {
+	Args() []string
+}
+
+// the stages in the order they are applied, the Headers and the Flags can be anywhere
+var stageNames = []string{"Rows, Cols, Types", "Bucket", "Filter", "Group, Stats", "Having", "Calc", "Sort", "Uniq",
+	"Top", "Limit", "Tail"}
+
+// stageOrder returns the position of the stage in the stageNames, -1 if the stage can be anywhere
+func stageOrder(stage Stage) int {
+	switch stage.(type) {
+	case Rows, Cols, Types:
+		return 0
+	case Bucket:
+		return 1
+	case Filter:
+		return 2
+	case Group, Stats:
+		return 3
+	case Having:
+		return 4
+	case Calc:
+		return 5
+	case Sort:
+		return 6
+	case Uniq:
+		return 7
+	case Top:
+		return 8
+	case Limit:
+		return 9
+	case Tail:
+		return 10
+	}
+	return -1
+}
+
+// checkOrder returns an error if a stage is before a stage which is applied earlier eg. a Limit before the Sort
+func checkOrder(stages []Stage) error {
+	var last Stage
+	for _, stage := range stages {
+		order := stageOrder(stage)
+		if order < 0 {
+			continue
+		}
+		if last != nil && order < stageOrder(last) {
+			hint := ""
+			if _, isFilter := stage.(Filter); isFilter {
+				hint = ". The groups are filtered by the Having"
+			}
+			return fmt.Errorf("The stage %T is after %T. The stages are applied in the order %v%v", stage, last,
+				strings.Join(stageNames, ", "), hint)
+		}
+		last = stage
+	}
+	return nil
+}
+
+// checkFields returns an error if a field of the stage has the delimiter of its flag, the flag would be parsed wrongly
+func checkFields(stage Stage) error {
+	switch s := stage.(type) {
+	case Rows:
+		return checkField(s, "Range", s.Range, "]")
+	case Cols:
+		return checkField(s, "Cols", s.Cols, "]")
+	case Filter:
+		return checkExpr(s, s.Expr)
+	case Group:
+		return firstError(checkField(s, "Cols", s.Cols, "]"), checkField(s, "Aggs", s.Aggs, "]"))
+	case Having:
+		return checkExpr(s, s.Expr)
+	case Sort:
+		return checkField(s, "Cols", s.Cols, "]")
+	case Uniq:
+		return checkField(s, "Cols", s.Cols, "]")
+	case Top:
+		return firstError(checkField(s, "By", s.By, "]", "="), checkField(s, "Per", s.Per, "]", "="))
+	case Types:
+		return checkField(s, "Types", s.Types, "]")
+	case Bucket:
+		return firstError(checkField(s, "Cols", s.Cols, "]"), checkField(s, "Interval", s.Interval, "]", ","))
+	case Headers:
+		for _, header := range s {
+			if err := checkField(s, "header", header, "]", ","); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkField(stage Stage, name string, value string, delims ...string) error {
+	for _, delim := range delims {
+		if strings.Contains(value, delim) {
+			return fmt.Errorf("The %T %v '%v' cannot have '%v', it is a delimiter of the flag", stage, name, value, delim)
+		}
+	}
+	return nil
+}
+
+// the expression follows the .. of the filter and the having, a leading . would be a part of the delimiter
+func checkExpr(stage Stage, expr string) error {
+	if strings.HasPrefix(expr, ".") {
+		return fmt.Errorf("The %T Expr '%v' cannot start with '.', it is a delimiter of the flag", stage, expr)
+	}
+	return checkField(stage, "Expr", expr, "..")
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flags are the flags of the csv command as is eg. Flags{"join[owners.csv,left=0]", "win..rank[LAG]"}
+type Flags []string
+
+func (f Flags) Args() []string {
+	return f
+}
+
+// Rows selects the rows by the range eg. "1:10". The header is the row 0
+type Rows struct {
+	Range string
+}
+
+func (s Rows) Args() []string {
+	return []string{"row[" + s.Range + "]"}
+}
+
+// Cols selects the columns, or removes the columns if Exclude is set
+type Cols struct {
+	Cols    string
+	Exclude bool
+}
+
+func (s Cols) Args() []string {
+	if s.Exclude {
+		return []string{"ncol[" + s.Cols + "]"}
+	}
+	return []string{"col[" + s.Cols + "]"}
+}
+
+// Filter retains the rows that match the expression eg. "[LAG] > 0"
+type Filter struct {
+	Expr string
+}
+
+func (s Filter) Args() []string {
+	return []string{"filter.." + s.Expr}
+}
+
+// Calc adds a column with the value of the expression eg. "[LOG-END-OFFSET]-[CURRENT-OFFSET]"
+type Calc struct {
+	Expr string
+}
+
+func (s Calc) Args() []string {
+	return []string{"calc(" + s.Expr + ")"}
+}
+
+// Group groups the rows by the columns. The Aggs are the aggregates of the rest of the columns eg. "LAG=sum,1=max"
+type Group struct {
+	Cols  string
+	Aggs  string
+	Count bool
+}
+
+func (s Group) Args() []string {
+	arg := "group[" + s.Cols + "]"
+	if s.Aggs != "" {
+		arg += ":agg[" + s.Aggs + "]"
+	}
+	if s.Count {
+		arg += ":count"
+	}
+	return []string{arg}
+}
+
+// Having retains the groups that match the expression eg. "[count] > 1"
+type Having struct {
+	Expr string
+}
+
+func (s Having) Args() []string {
+	return []string{"having.." + s.Expr}
+}
+
+type Sort struct {
+	Cols string
+	Desc bool
+}
+
+func (s Sort) Args() []string {
+	if s.Desc {
+		return []string{"sort[" + s.Cols + "]:desc"}
+	}
+	return []string{"sort[" + s.Cols + "]"}
+}
+
+// Uniq removes the duplicate rows. The rows are compared by the Cols, or by the whole row if there are no Cols
+type Uniq struct {
+	Cols  string
+	Count bool
+}
+
+func (s Uniq) Args() []string {
+	arg := "uniq"
+	if s.Cols != "" {
+		arg = "uniq[" + s.Cols + "]"
+	}
+	if s.Count {
+		arg += ":count"
+	}
+	return []string{arg}
+}
+
+// Top retains the rows with the largest values of the By column, for each group of the Per columns if set
+type Top struct {
+	Count int
+	By    string
+	Per   string
+}
+
+func (s Top) Args() []string {
+	arg := fmt.Sprintf("top[%v,by=%v", s.Count, s.By)
+	if s.Per != "" {
+		arg += ",per=" + s.Per
+	}
+	return []string{arg + "]"}
+}
+
+// Limit retains the first N rows
+type Limit int
+
+func (s Limit) Args() []string {
+	return []string{fmt.Sprintf("limit:%d", int(s))}
+}
+
+// Tail retains the last N rows
+type Tail int
+
+func (s Tail) Args() []string {
+	return []string{fmt.Sprintf("tail:%d", int(s))}
+}
+
+// Types sets the types of the columns eg. "AGE=duration,MEMORY=bytes"
+type Types struct {
+	Types string
+}
+
+func (s Types) Args() []string {
+	return []string{"types[" + s.Types + "]"}
+}
+
+// Bucket truncates the times of the columns to the Interval eg. Bucket{Cols: "@timestamp", Interval: "5m"}
+type Bucket struct {
+	Cols     string
+	Interval string
+}
+
+func (s Bucket) Args() []string {
+	return []string{"bucket[" + s.Cols + "," + s.Interval + "]"}
+}
+
+// Stats profiles the columns, a row of the stats for each column
+type Stats struct{}
+
+func (s Stats) Args() []string {
+	return []string{"stats"}
+}
+
+// Headers sets the names of the output columns
+type Headers []string
+
+func (s Headers) Args() []string {
+	return []string{"head[" + strings.Join(s, ",") + "]"}
+}
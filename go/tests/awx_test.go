@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+// the aws cli is replaced by a script which prints the describe-images response
+func TestAwxAmiListOutput(t *testing.T) {
+	dir := t.TempDir()
+	params := path.Join(dir, "params")
+	if err := os.WriteFile(params, []byte("ec2:\n  amiTypeTag: base\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	images := `{"Images":[{"ImageId":"ami-1","Name":"base-1","State":"available","VirtualizationType":"hvm"},` +
+		`{"ImageId":"ami-2","Name":"base-2","State":"pending","VirtualizationType":"hvm"}]}`
+	script := fmt.Sprintf("#!/bin/bash\necho '%v'\n", images)
+	if err := os.WriteFile(path.Join(dir, "aws"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := fmt.Sprintf("PATH=%v:$PATH PARAM_FILE=%v", dir, params)
+
+	lines := execCmdGetLines(fmt.Sprintf("%v awx ami ls", env))
+	assertStringEquals(lines[0], "SL    AMI      NAME      STATE        VIRT    TAGS    ")
+
+	lines = execCmdGetLines(fmt.Sprintf("%v awx ami ls sort[STATE]:desc out..jsonl", env))
+	assertStringEquals(lines[0], `{"SL":2,"AMI":"ami-2","NAME":"base-2","STATE":"pending","VIRT":"hvm","TAGS":{}}`)
+	assertStringEquals(lines[1], `{"SL":1,"AMI":"ami-1","NAME":"base-1","STATE":"available","VIRT":"hvm","TAGS":{}}`)
+
+	lines = execCmdGetLines(fmt.Sprintf("%v awx ami ls out..md", env))
+	assertStringEquals(lines[0], "| SL  | AMI   | NAME   | STATE     | VIRT | TAGS |")
+
+	lines = execCmdGetError(fmt.Sprintf("%v awx ami ls out..bogus", env))
+	assertStringEquals(lines[0][20:], "Unknown output 'bogus'. The outputs are csv, json, table, kv, md, html, yaml, jsonl, tsv, view, bar, hist")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path"
+	"strings"
 	"testing"
 )
 
@@ -175,9 +176,9 @@ func TestCSVColGroupAndSort(t *testing.T) {
 	lines = execCmdGetLines(cmd)
 	assertIntEquals(len(lines), 9)
 	assertStringEquals(lines[0], "TOPIC     HOST           CURRENT-OFFSET    LOG-END-OFFSET    LAG    ")
-	assertStringEquals(lines[1], "topic1    consumer-5     6468718           6468718           0      ")
+	assertStringEquals(lines[1], "topic1    consumer-5            6468718           6468718      0    ")
 	assertStringEquals(lines[2], "          consumer-6                                                ")
-	assertStringEquals(lines[7], "topic3    consumer-21    26984839          26984839          0      ")
+	assertStringEquals(lines[7], "topic3    consumer-21          26984839          26984839      0    ")
 
 	// string merge cols
 	cmd = fmt.Sprintf("cat %v | csv col[0,6,2,3,4] group[0] sort[0,2] out..csv", fpath)
@@ -255,6 +256,581 @@ func TestCSVColGroupAndSort(t *testing.T) {
 
 }
 
+func TestCSVGroupAggregates(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1,2,6] group[0]:agg[1=max,2=avg,3=distinct] sort[0]", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,CURRENT-OFFSET,HOST")
+	assertStringEquals(lines[1], "topic1,51,808589.75,2")
+	assertStringEquals(lines[2], "topic2,7,27.25,4")
+	assertStringEquals(lines[3], "topic3,0,26984839,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,2,6] group[0]:agg[1=min,2=p95,3=first]:count sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,CURRENT-OFFSET,HOST,count")
+	assertStringEquals(lines[1], "topic1,44,809526.40,consumer-5,8")
+	assertStringEquals(lines[2], "topic2,0,33.65,consumer-4,8")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,2,6] group[0]:agg[1=median,2=sum,3=last] sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[1], "topic1,47.50,6468718,consumer-6")
+	assertStringEquals(lines[2], "topic2,3.50,218,consumer-2")
+}
+
+func TestCSVFilterAndHaving(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1] 'filter..[1] > 48 && [0] == \"topic1\"'", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,PARTITION")
+	assertStringEquals(lines[1], "topic1,49")
+	assertStringEquals(lines[3], "topic1,51")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] 'filter..[PARTITION] < 2' out..table", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[1], "topic2            0    ")
+	assertStringEquals(lines[3], "topic3            0    ")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,6] group[0,1]:count 'having..count>2' sort[0,1]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[0], "TOPIC,HOST,count")
+	assertStringEquals(lines[1], "topic1,consumer-5,4")
+	assertStringEquals(lines[2], "topic1,consumer-6,4")
+}
+
+func TestCSVExternalSort(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	//the low threshold forces the sorted runs to be spilled into temp files
+	cmd := fmt.Sprintf("cat %v | csv col[0,1] sort[1]:desc mem:1k", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 19)
+	assertStringEquals(lines[0], "TOPIC,PARTITION")
+	assertStringEquals(lines[1], "topic1,51")
+	assertStringEquals(lines[8], "topic1,44")
+	assertStringEquals(lines[9], "topic2,7")
+	assertStringEquals(lines[17], "topic3,0")
+
+	cmd = fmt.Sprintf("cat %v | csv out..csv sort[2] head[topic,partition,in,out,lag,consumer,client,calc] 'calc([2]+[3])' mem:1k", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 19)
+	assertStringEquals(lines[0], "topic,partition,in,out,lag,consumer,client,calc")
+	assertStringEquals(lines[1], "topic2,2,21,21,0,consumer-2-ebe5eadf-8712-4b84-8951-afc00117e325/10.9.27.3,consumer-2,42")
+	assertStringEquals(lines[17], "topic3,0,26984839,26984839,0,consumer-21-2296df7b-b059-4748-9d11-3c6a8a147be1/10.9.27.3,consumer-21,53969678")
+}
+
+func TestCSVJoin(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+	owners := path.Join(getCurrentDir(t), "owners.csv")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1] join[%v,left=0,right=0] row[0:3]", fpath, owners)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,owner,team")
+	assertStringEquals(lines[1], "topic1,44,alice,payments")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] join[%v,type=inner] group[0,2]:count sort[0]", fpath, owners)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[0], "TOPIC,owner,PARTITION,team,count")
+	assertStringEquals(lines[1], "topic1,alice,380,payments,8")
+	assertStringEquals(lines[2], "topic2,bob,28,search,8")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0] join[%v,type=left] group[0,1]:count sort[0]", fpath, owners)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[3], "topic3,,,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0] join[%v,type=right] group[0,1]:count sort[0]", fpath, owners)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[3], "topic4,carol,billing,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0] join[%v,type=full] group[0]:count sort[0]", fpath, owners)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[3], "topic3,,,1")
+	assertStringEquals(lines[4], "topic4,carol,billing,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,4] join[%v,type=left] 'filter..[team]==\"search\"' group[0]:count", fpath, owners)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[1], "topic2,0,bob,search,8")
+}
+
+func TestCSVPivot(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv pivot[row=0,col=6]", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,consumer-1,consumer-2,consumer-21,consumer-3,consumer-4,consumer-5,consumer-6")
+	assertStringEquals(lines[1], "topic1,,,,,,4,4")
+	assertStringEquals(lines[2], "topic2,2,2,,2,2,,")
+	assertStringEquals(lines[3], "topic3,,,1,,,,")
+
+	cmd = fmt.Sprintf("cat %v | csv pivot[row=TOPIC,col=HOST,val=PARTITION,agg=max]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "topic1,,,,,,47,51")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,4] row[0:3] unpivot[1:]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[0], "TOPIC,key,value")
+	assertStringEquals(lines[1], "topic1,PARTITION,44")
+	assertStringEquals(lines[2], "topic1,LAG,0")
+	assertStringEquals(lines[3], "topic1,PARTITION,45")
+
+	cmd = fmt.Sprintf("cat %v | csv pivot[row=0,col=6] unpivot[1:] sort[0,1]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 23)
+	assertStringEquals(lines[1], "topic1,consumer-1,")
+	assertStringEquals(lines[6], "topic1,consumer-5,4")
+}
+
+func TestCSVWindow(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1,2] sort[0,2] win..part[0]..row_number..sum[2]..delta[CURRENT-OFFSET]", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 19)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,CURRENT-OFFSET,row_number,sum(CURRENT-OFFSET),delta(CURRENT-OFFSET)")
+	assertStringEquals(lines[1], "topic1,49,807305,1,807305,")
+	assertStringEquals(lines[2], "topic1,45,808133,2,1615438,828")
+	assertStringEquals(lines[9], "topic2,2,21,1,21,")
+	assertStringEquals(lines[10], "topic2,5,22,2,43,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,4] sort[0] win..rank..prev[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "topic1,0,1,")
+	assertStringEquals(lines[8], "topic1,0,1,topic1")
+	assertStringEquals(lines[9], "topic2,0,9,topic1")
+	assertStringEquals(lines[17], "topic3,0,17,topic2")
+}
+
+func TestCSVDiff(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+	prev := path.Join(getCurrentDir(t), "topics_prev.csv")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1,2,4] diff..%v..key[0,1]", fpath, prev)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,change,CURRENT-OFFSET,LAG")
+	assertStringEquals(lines[1], "topic1,44,changed,808600→808699 (+99),12→0 (-12)")
+	assertStringEquals(lines[2], "topic1,45,changed,808100→808133 (+33),0")
+	assertStringEquals(lines[3], "topic3,0,added,26984839,0")
+	assertStringEquals(lines[4], "topic2,8,removed,19,0")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,2,4] diff..%v..key[TOPIC,PARTITION] sort[change] out..jsonl", fpath, prev)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], `{"TOPIC":"topic3","PARTITION":"0","change":"added","CURRENT-OFFSET":"26984839","LAG":"0"}`)
+	assertStringEquals(lines[3], `{"TOPIC":"topic2","PARTITION":"8","change":"removed","CURRENT-OFFSET":"19","LAG":"0"}`)
+}
+
+func TestCSVUniqTopLimit(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,6] uniq", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 9)
+	assertStringEquals(lines[1], "topic1,consumer-5")
+	assertStringEquals(lines[2], "topic1,consumer-6")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,6] uniq[TOPIC]:count", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,HOST,count")
+	assertStringEquals(lines[1], "topic1,consumer-5,8")
+	assertStringEquals(lines[3], "topic3,consumer-21,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,2] 'top[2 by CURRENT-OFFSET per TOPIC]'", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 7)
+	assertStringEquals(lines[1], "topic1,46,809735")
+	assertStringEquals(lines[2], "topic1,51,809139")
+	assertStringEquals(lines[3], "topic2,0,34")
+	assertStringEquals(lines[5], "topic3,0,26984839")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,2] top[2,by=2] limit:1", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[1], "topic3,0,26984839")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] sort[1] tail:2", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[1], "topic1,50")
+	assertStringEquals(lines[2], "topic1,51")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv col[0,1] limit:0", fpath))
+	assertStringEquals(lines[0][20:], "Invalid limit 'limit:0'. A positive number is expected eg. limit:10")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv col[0,1] tail:-2", fpath))
+	assertStringEquals(lines[0][20:], "Invalid tail 'tail:-2'. A positive number is expected eg. tail:10")
+}
+
+func TestCSVTypes(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "pods_usage.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,3,4] sort[AGE]", fpath)
+	lines := execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "api-2,1Gi,45m")
+	assertStringEquals(lines[2], "worker-2,2Gi,5h30m")
+	assertStringEquals(lines[5], "db-1,8Gi,120d")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,3] sort[1]:desc", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "db-1,8Gi")
+	assertStringEquals(lines[5], "worker-1,256Mi")
+
+	cmd = fmt.Sprintf("cat %v | csv types[CPU=cpu] col[1:6] 'group[NAMESPACE]:agg[CPU=sum,MEMORY=sum,AGE=max,READY=avg]' sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "NAMESPACE,CPU,MEMORY,AGE,READY")
+	assertStringEquals(lines[1], "default,1250m,1.5Gi,3d4h,75%")
+	assertStringEquals(lines[2], "jobs,1600m,2.25Gi,10d,87.5%")
+	assertStringEquals(lines[3], "storage,2,8Gi,120d,100%")
+
+	// the cpu column mixes 250m and 1, so it is not inferred as a duration without the types[]
+	cmd = fmt.Sprintf("cat %v | csv col[0,2] sort[CPU]:desc", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "api-1,250m")
+	assertStringEquals(lines[2], "db-1,2")
+	assertStringEquals(lines[5], "api-2,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[1,2] group[0] sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], `default,"1,250m"`)
+	assertStringEquals(lines[3], "storage,2")
+
+	// the times and the percents are collected, those are not added up
+	cmd = fmt.Sprintf("cat %v | csv col[1,5,6] group[0] sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], `default,"100%,50%","2021-10-12T10:00:00Z,2021-10-15T09:15:00Z"`)
+	assertStringEquals(lines[3], "storage,100%,2021-06-17T00:00:00Z")
+
+	cmd = fmt.Sprintf("cat %v | csv types[1=cpu] col[1,2] group[0] sort[0] out..json", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], `[{"CPU":"1250m","NAMESPACE":"default"},{"CPU":"1600m","NAMESPACE":"jobs"},{"CPU":"2","NAMESPACE":"storage"}]`)
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,6] sort[STARTED] win..delta[STARTED]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[2], "worker-1,2021-10-05T08:30:00Z,110d8h30m")
+	assertStringEquals(lines[5], "api-2,2021-10-15T09:15:00Z,4h30m")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,3,4] 'calc([MEMORY]/1024/1024)' 'filter..[AGE] > 86400'", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[1], "api-1,512Mi,3d4h,512")
+	assertStringEquals(lines[3], "db-1,8Gi,120d,8192")
+}
+
+func TestCSVRecipes(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+	env := fmt.Sprintf("UTILBOX_PATH=%v", t.TempDir())
+
+	lines := execCmdGetLines(fmt.Sprintf(`%v bk add recipe lag "col[0,1,4] 'filter..[PARTITION] > 45' group[0]:count sort[0]"`, env))
+	assertStringEquals(lines[0], "INFO:RECIPE_ADDED [lag=col[0,1,4] 'filter..[PARTITION] > 45' group[0]:count sort[0]]")
+	execCmd(fmt.Sprintf(`%v bk add recipe table "@lag out..table"`, env))
+
+	lines = execCmdGetLines(fmt.Sprintf("%v bk get recipe lag", env))
+	assertStringEquals(lines[0], "col[0,1,4] 'filter..[PARTITION] > 45' group[0]:count sort[0]")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | %v csv @lag", fpath, env))
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,LAG,count")
+	assertStringEquals(lines[1], "topic1,291,0,6")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | %v csv @lag out..jsonl", fpath, env))
+	assertStringEquals(lines[0], `{"TOPIC":"topic1","PARTITION":291,"LAG":0,"count":6}`)
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | %v csv @table", fpath, env))
+	assertStringEquals(strings.TrimSpace(lines[0]), "TOPIC     PARTITION    LAG    count")
+}
+
+func TestCSVHeaderNames(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[TOPIC,LAG,PARTITION] row[0:2]", fpath)
+	lines := execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "TOPIC,LAG,PARTITION")
+	assertStringEquals(lines[1], "topic1,0,44")
+
+	cmd = fmt.Sprintf("cat %v | csv ncol[CONSUMER-ID,HOST,CLIENT-ID] row[0:2] 'calc([CURRENT-OFFSET]+[1])'", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,CURRENT-OFFSET,LOG-END-OFFSET,LAG,CURRENT-OFFSET+PARTITION")
+	assertStringEquals(lines[1], "topic1,44,808699,808699,0,808743")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] group[TOPIC]:agg[PARTITION=max]:count sort[count,TOPIC]:desc", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "TOPIC,PARTITION,count")
+	assertStringEquals(lines[1], "topic2,7,8")
+	assertStringEquals(lines[2], "topic1,51,8")
+	assertStringEquals(lines[3], "topic3,0,1")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,5] tr..cCONSUMER-ID..split:/..col[-1] sort[PARTITION]:desc row[0:2]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "topic1,44,10.9.27.3")
+}
+
+func TestCSVSplitColumns(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "pods_aligned.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv split:columns", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 7)
+	assertStringEquals(lines[0], "NAME,READY,STATUS,RESTARTS,AGE,IP,NODE,NOMINATED NODE,READINESS GATES")
+	assertStringEquals(lines[3], "worker-5c6f7b8d9-qwert,0/1,CrashLoopBackOff,14,2h,10.1.1.7,node-2,,")
+	assertStringEquals(lines[5], "job-init-x7k2p,0/1,Completed,0,1d,,,,")
+
+	cmd = fmt.Sprintf("cat %v | csv split:columns 'col[NAME,NOMINATED NODE]' row[0:2]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "NAME,NOMINATED NODE")
+	assertStringEquals(lines[1], "api-7d4b9c8f6-2xkqp,<none>")
+
+	cmd = fmt.Sprintf("cat %v | csv split:columns col[NODE] group[0]:count sort[0]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[1], ",1")
+	assertStringEquals(lines[3], "node-2,2")
+
+	//the columns separated by less than 4 spaces are not split
+	cmd = fmt.Sprintf("cat %v | csv split:columns:4 row[0:1]", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "NAME,READY   STATUS,RESTARTS   AGE   IP,NODE,NOMINATED NODE   READINESS GATES")
+}
+
+func TestCSVSplitKvBlock(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "vms_long.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv 'split:kvblock..start:^Name:'", fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "Name,Groups,Guest OS,Memory size,Number of CPUs,State,Description")
+	assertStringEquals(lines[1], `dev-box,/,Ubuntu (64-bit),4096MB,2,running (since 2024-01-10T08:15:02.120000000),"base image,with docker"`)
+	assertStringEquals(lines[3], "win-box,,Windows 10 (64-bit),8192MB,4,saved (since 2024-01-08T12:00:00.000000000),")
+
+	cmd = fmt.Sprintf("cat %v | kvp 'split:kvblock..start:^Name:' 'col[Name,Memory size]' 'sort[Memory size]:desc' out..jsonl", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[0], `{"Name":"win-box","Memory size":"8192MB"}`)
+
+	//the blocks are separated by the blank lines if there is no start
+	lines = execCmdGetLines(`printf 'Id=a\nState=active\n\nId=b\nState=failed\nPID=12\n' | kvp split:kvblock..sep:= 'filter..[State] == "failed"'`)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], "Id,State,PID")
+	assertStringEquals(lines[1], "b,failed,12")
+}
+
+func TestCSVDialect(t *testing.T) {
+	data := `printf '\xef\xbb\xbfname;age\n# comment\nbob; 3\n"al"ice;4\ncarl;5;x\ndan;6\n'`
+
+	lines := execCmdGetError(data + " | csv 'split:csv..sep:;..comment:#..bom..trim'")
+	assertStringEquals(lines[0][20:], "Malformed csv record, parse error on line 4, column 4: extraneous or missing \" in "+
+		"quoted-field. The options split:csv..lazy or split:csv..skip-bad can be used")
+
+	lines = execCmdGetLines(data + " | csv 'split:csv..sep:;..comment:#..bom..trim..skip-bad' 2>/dev/null")
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "name,age")
+	assertStringEquals(lines[1], "bob,3")
+	assertStringEquals(lines[2], "carl,5,x")
+	assertStringEquals(lines[3], "dan,6")
+
+	lines = execCmdGetLines(data + " | csv 'split:csv..sep:;..comment:#..bom..trim..skip-bad..strict' 2>/dev/null")
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[2], "dan,6")
+
+	// the rows can have a different number of fields unless it is strict
+	lines = execCmdGetLines(`printf 'a,b\n1,2\n3\n4,5\n' | csv split:csv`)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[2], "3")
+	assertStringEquals(lines[3], "4,5")
+
+	lines = execCmdGetLines(`printf 'a|b\n1|2|3\n4\n' | csv split:csv..sep:pipe out..jsonl`)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], `{"a":"1","b":"2","2":"3"}`)
+	assertStringEquals(lines[1], `{"a":"4"}`)
+
+	lines = execCmdGetError(`printf 'a,b\n1,2\n3\n' | csv split:csv..strict`)
+	assertStringEquals(lines[0][20:], "Malformed csv record, record on line 3: wrong number of fields. The options "+
+		"split:csv..lazy or split:csv..skip-bad can be used")
+
+	lines = execCmdGetError(`printf 'a,b\n' | csv split:csv..sep:ab`)
+	assertStringEquals(lines[0][20:], "Invalid char 'ab' in 'split:csv..sep:ab'. A single char is expected")
+}
+
+func TestCSVStats(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "pods_aligned.txt")
+
+	lines := execCmdGetLines(fmt.Sprintf("cat %v | csv split:columns stats out..csv", fpath))
+	assertIntEquals(len(lines), 11)
+	assertStringEquals(lines[0], "column,type,count,empty,distinct,min,max,mean,stddev,top,histogram")
+	assertStringEquals(lines[3], `STATUS,string,5,0,3,,,,,"Running (3), Completed (1), CrashLoopBackOff (1)",`)
+	assertStringEquals(lines[4], `RESTARTS,int,5,0,3,0,14,3.20,6.10,"0 (3), 14 (1), 2 (1)",█▃     ▃`)
+	assertStringEquals(lines[5], `AGE,duration,5,0,4,2h,12d,4d14h48m,4d16h51m1s,"5d (2), 12d (1), 1d (1), 2h (1)",█  █   ▄`)
+	assertStringEquals(lines[8], "NOMINATED NODE,string,5,2,1,,,,,<none> (3),")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | csv split:columns stats col[2,3] 'filter..[STATUS] == \"Running\"' out..csv", fpath))
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[1], "STATUS,string,3,0,1,,,,,Running (3),")
+	assertStringEquals(lines[2], `RESTARTS,int,3,0,2,0,2,0.67,1.15,"0 (2), 2 (1)",█      ▄`)
+
+	// the distinct values are held upto 10000, the distinct is a lower bound beyond that
+	lines = execCmdGetLines("(echo n; seq 1 10005) | csv stats col[0] out..csv 2>/dev/null")
+	assertStringEquals(strings.Join(strings.Split(lines[1], ",")[:9], ","), "n,int,10005,0,>10000,1,10005,5003,2888.34")
+
+	lines = execCmdGetLines("(echo n; seq 1 10005) | csv stats out..csv 2>&1 >/dev/null")
+	assertStringEquals(lines[0][20:], "The column n has more than 10000 distinct values, the top and the histogram are "+
+		"based on the first 10000")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv split:columns group[0]:count stats", fpath))
+	assertStringEquals(lines[0][20:], "The stats cannot be used with group. The stats profile the rows after the col, row, filter and bucket")
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv split:columns stats 'calc([2]+1)' sort[0] limit:2", fpath))
+	assertStringEquals(lines[0][20:], "The stats cannot be used with calc, sort, limit. The stats profile the rows after the col, row, filter and bucket")
+}
+
+func TestCSVOutputFormats(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf("cat %v | csv col[0,1,6] group[0] sort[0] out..md", fpath)
+	lines := execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "| TOPIC  | PARTITION | HOST                                                 |")
+	assertStringEquals(lines[1], "| ------ | --------- | ---------------------------------------------------- |")
+	assertStringEquals(lines[2], "| topic1 | 380       | consumer-5<br>consumer-6                             |")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,6] group[0] sort[0] out..html", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[2], "<tr><th>TOPIC</th><th>PARTITION</th><th>HOST</th></tr>")
+	assertStringEquals(lines[7], "<tr><td>topic3</td><td>0</td><td>consumer-21</td></tr>")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1,6] group[0] sort[0] out..jsonl", fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[0], `{"TOPIC":"topic1","PARTITION":380,"HOST":["consumer-5","consumer-6"]}`)
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] group[0] sort[0] out..yaml", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "- TOPIC: topic1")
+	assertStringEquals(lines[1], "  PARTITION: 380")
+
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] row[0:2] out..tsv", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "TOPIC\tPARTITION")
+	assertStringEquals(lines[1], "topic1\t44")
+
+	lines = execCmdGetLines(`printf 'a,b\n"x\ty","p|q\nr"\n' | csv split:csv out..tsv`)
+	assertStringEquals(lines[1], "x\\ty\tp|q\\nr")
+	lines = execCmdGetLines(`printf 'a,b\n"x","p|q\nr"\n' | csv split:csv out..md`)
+	assertStringEquals(lines[2], "| x   | p\\|q<br>r |")
+
+	// the view is the table if the output is not a terminal
+	cmd = fmt.Sprintf("cat %v | csv col[0,1] group[0] sort[0] out..view", fpath)
+	lines = execCmdGetLines(cmd)
+	assertStringEquals(lines[0], "TOPIC     PARTITION    ")
+	assertStringEquals(lines[1], "topic1          380    ")
+}
+
+func TestCSVTableOutput(t *testing.T) {
+	data := `printf 'name,city,n\n山田太郎,東京,12\nBob 🚀,Zürich,7\nAnn,Seoul 서울,100\n'`
+	lines := execCmdGetLines(data + " | csv split:csv out..table")
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "name        city            n    ")
+	assertStringEquals(lines[1], "山田太郎    東京           12    ")
+	assertStringEquals(lines[2], "Bob 🚀      Zürich          7    ")
+	assertStringEquals(lines[3], "Ann         Seoul 서울    100    ")
+
+	lines = execCmdGetLines(data + " | csv split:csv out..table..style:ascii")
+	assertIntEquals(len(lines), 8)
+	assertStringEquals(lines[0], "+----------+------------+-----+")
+	assertStringEquals(lines[1], "| name     | city       |   n |")
+	assertStringEquals(lines[3], "| 山田太郎 | 東京       |  12 |")
+	assertStringEquals(lines[6], "+----------+------------+-----+")
+
+	fpath := path.Join(getCurrentDir(t), "pods_aligned.txt")
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | csv split:columns col[NAME,STATUS] row[1:3] out..table..colwidth:12", fpath))
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[1], "api-7d4b9c8…    Running    ")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | csv split:columns col[NAME,STATUS] row[1:3] out..table..colwidth:12..wrap", fpath))
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[1], "api-7d4b9c8f    Running    ")
+	assertStringEquals(lines[2], "6-2xkqp                    ")
+
+	// the text columns are shrunk to fit the COLUMNS, the numbers are retained
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | COLUMNS=30 csv split:columns col[NAME,STATUS,RESTARTS] row[1:3] out..table", fpath))
+	assertStringEquals(lines[0], "NAME        STATUS     RESTARTS    ")
+	assertStringEquals(lines[1], "api-7d4…    Running           0    ")
+
+	lines = execCmdGetError(data + " | csv split:csv out..table..style:fancy")
+	assertStringEquals(lines[0][20:], "Unknown table style 'fancy'. The styles are plain, ascii, box, rounded")
+
+	lines = execCmdGetError(data + " | csv split:csv out..table..box")
+	assertStringEquals(lines[0][20:], "Unknown output option 'box' in 'out..table..box', did you mean 'style:box'?")
+	lines = execCmdGetError(data + " | csv split:csv out..table..wrp")
+	assertStringEquals(lines[0][20:], "Unknown output option 'wrp' in 'out..table..wrp', did you mean 'wrap'?")
+	lines = execCmdGetError(data + " | csv split:csv out..table..bogus")
+	assertStringEquals(lines[0][20:], "Unknown output option 'bogus' in 'out..table..bogus'. "+
+		"The options are levels:, flatten, bins:, style:, colwidth:, wrap")
+}
+
+func TestCSVChartOutput(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "pods_aligned.txt")
+
+	lines := execCmdGetLines(fmt.Sprintf("cat %v | COLUMNS=60 csv split:columns group[STATUS]:count sort[0] out..bar", fpath))
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "STATUS            count")
+	assertStringEquals(lines[2], "CrashLoopBackOff  █████████████▍ 1")
+	assertStringEquals(lines[3], "Running           ████████████████████████████████████████ 3")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | COLUMNS=60 csv split:columns col[0,3] -outhead out..bar", fpath))
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[0], "api-7d4b9c8f6-2xkqp   0")
+	assertStringEquals(lines[2], "worker-5c6f7b8d9-qw…  ███████████████████████████████████ 14")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | COLUMNS=60 csv split:columns col[AGE] out..hist..bins:4", fpath))
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[0], "AGE             count")
+	assertStringEquals(lines[1], "2h - 3d1h30m    ██████████████████████████████████████████ 2")
+	assertStringEquals(lines[3], "6d1h - 9d30m    0")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv split:columns col[0] out..bar", fpath))
+	assertStringEquals(lines[0][20:], "The out..bar needs a numeric column eg. group[0]:count out..bar")
+}
+
+func TestCSVExprFunctions(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	cmd := fmt.Sprintf(`cat %v | csv col[0,5] row[0:2] 'calc(upper([0]))' 'calc(replace([0],"topic","t-"))' 'calc(regex_extract([1],"consumer-(\\d+)"))' 'calc(if(len([1])>50,"long","short"))' 'calc(coalesce([5],"none"))'`, fpath)
+	lines := execCmdGetLines(cmd)
+	assertStringEquals(lines[1], "topic1,consumer-5-c6ac0ffe-b453-41ad-a3a4-2b1265735ed3/10.9.27.3,TOPIC1,t-1,5,long,long")
+
+	data := `printf 'name,age,size\na,5d3h,512Mi\nb,45m,1.5G\nc,3d,10Ki\n'`
+	lines = execCmdGetLines(data + ` | csv split:csv 'calc(duration([1])/3600)' 'calc(bytes([2]))'`)
+	assertStringEquals(lines[0], "name,age,size,duration(age)/3600,bytes(size)")
+	assertStringEquals(lines[1], "a,5d3h,512Mi,123,536870912")
+	assertStringEquals(lines[2], "b,45m,1.5G,0.75,1500000000")
+
+	lines = execCmdGetLines(data + ` | csv split:csv 'filter..age([age]) > "2d"'`)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[1], "a,5d3h,512Mi")
+	assertStringEquals(lines[2], "c,3d,10Ki")
+
+	lines = execCmdGetLines(data + ` | csv split:csv 'filter..bytes([2]) >= "1Gi" || "1h" > age([1])'`)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[1], "b,45m,1.5G")
+
+	lines = execCmdGetLines(`printf 'name,created\na,2021-06-01T10:30:00Z\n' | csv split:csv 'calc(parse_time([1]))' 'calc(age([1])>86400)'`)
+	assertStringEquals(lines[1], "a,2021-06-01T10:30:00Z,1622543400,true")
+}
+
 func TestCsvHeader(t *testing.T) {
 	//dont print header
 	fpath := path.Join(getCurrentDir(t), "topics.txt")
@@ -337,3 +913,63 @@ func unMarshallJsonString(str string) []map[string]interface{} {
 	json.Unmarshal([]byte(str), &array)
 	return array
 }
+
+func TestCSVStrictArgs(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	lines := execCmdGetError(fmt.Sprintf("cat %v | csv 'sorrt[1]'", fpath))
+	assertStringEquals(lines[0][20:], "Unknown flag 'sorrt[1]', did you mean 'sort'? eg. sort[1]:desc")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'col[0,1:3:4]'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid column '1:3:4' in 'col[0,1:3:4]'")
+	assertStringEquals(lines[1], "    col[0,1:3:4]")
+	assertStringEquals(lines[2], "             ^")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'col[0,1'", fpath))
+	assertStringEquals(lines[0][20:], "Missing ']' in 'col[0,1'")
+	assertStringEquals(lines[2], "           ^")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv out..tabel", fpath))
+	assertStringEquals(lines[0][20:], "Unknown output 'tabel', did you mean 'table'?")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'col[TOPC,LAG]'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid col, unknown column 'TOPC', did you mean 'TOPIC'? The columns are "+
+		"[TOPIC PARTITION CURRENT-OFFSET LOG-END-OFFSET LAG CONSUMER-ID HOST CLIENT-ID]")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'group[9]:count'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid group, the column 9 does not exist. There are 8 columns")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'col[20]'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid col, the column 20 does not exist. There are 8 columns")
+	assertStringEquals(lines[2], "        ^")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'ncol[30]'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid ncol, the column 30 does not exist. There are 8 columns")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv col[0,1] 'filter..[2] > 1'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid filter, the column 2 does not exist. There are 2 columns")
+	assertStringEquals(lines[1], "    filter..[2] > 1")
+	assertStringEquals(lines[2], "             ^")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'group[0]:count' 'having..[20] > 1'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid having, the column 20 does not exist. There are 9 columns")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'calc([2]+[3])' 'calc([9]*2)'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid calc, the column 9 does not exist. There are 9 columns")
+	assertStringEquals(lines[2], "          ^")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv tr..c9..split:-", fpath))
+	assertStringEquals(lines[0][20:], "Invalid tr, the column 9 does not exist. There are 8 columns")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'bucket[9,5m]'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid bucket, the column 9 does not exist. There are 8 columns")
+
+	lines = execCmdGetError(fmt.Sprintf("cat %v | csv 'calc([2]+)'", fpath))
+	assertStringEquals(lines[0][20:], "Invalid calc([2]+). The error is Unexpected end of expression")
+	assertStringEquals(lines[1], "    calc([2]+)")
+	assertStringEquals(lines[2], "         ^")
+
+	// the end of a range is exclusive
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | csv 'col[6:8]' row[0:1]", fpath))
+	assertStringEquals(lines[0], "HOST,CLIENT-ID")
+}
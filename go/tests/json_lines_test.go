@@ -68,4 +68,55 @@ func TestJsonLineFilterWithKeys(t *testing.T) {
 			fmt.Println(line)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestLogfmtLine(t *testing.T) {
+	fileStr := path.Join(getCurrentDir(t), "logfmt.log")
+	lines := execCmdGetLines(fmt.Sprintf("cat %v | lfp keys", fileStr))
+	assertIntEquals(len(lines), 9)
+	assertStringEquals(lines[1], "http.path")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | lfp 'keys[level,msg,http.status]' out..csv", fileStr))
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "info,user login,200")
+	assertStringEquals(lines[1], `error,"db ""timeout""",500`)
+	assertStringEquals(lines[2], "plain text line")
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | lfp 'filter..[http.status] >= 500'", fileStr))
+	assertIntEquals(len(lines), 2)
+	assertStringEquals(lines[0], `ts=2024-01-10T08:15:03Z level=error msg="db \"timeout\"" user=7 http.status=500 retry`)
+
+	lines = execCmdGetLines(fmt.Sprintf("cat %v | lfp 'keys[user,retry]' 'filter..[level] == \"error\"' out..jsonl", fileStr))
+	assertStringEquals(lines[0], `{"user":"7","retry":"true"}`)
+}
+
+func TestJsonLineBucket(t *testing.T) {
+	fileStr := path.Join(getCurrentDir(t), "events.log")
+	cmd := fmt.Sprintf("cat %v | jpl 'keys[@timestamp,level]' | csv -inhead 'bucket[0,1m]' 'group[0,1]:count' 'sort[0,1]'", fileStr)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[0], "2024-03-01T10:00:00Z,error,1")
+	assertStringEquals(lines[1], "2024-03-01T10:00:00Z,info,2")
+	assertStringEquals(lines[2], "2024-03-01T10:01:00Z,error,3")
+
+	cmd = fmt.Sprintf("cat %v | jpl 'keys[@timestamp,level]' | csv -inhead 'bucket[0,5m]' 'filter..[1] == \"error\"' 'group[0]:count'", fileStr)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 2)
+	assertStringEquals(lines[0], "2024-03-01T10:00:00Z,error,4")
+
+	// the epoch and the common log format, the values which are not times are retained
+	data := `printf 'ts,status\n1709287212,500\n1709287275.5,200\n01/Mar/2024:10:02:00 +0000,500\nbad,200\n'`
+	lines = execCmdGetLines(data + " | csv split:csv 'bucket[ts,1m]'")
+	assertIntEquals(len(lines), 6)
+	assertStringEquals(lines[1], "2024-03-01T10:00:00Z,500")
+	assertStringEquals(lines[2], "2024-03-01T10:01:00Z,200")
+	assertStringEquals(lines[3], "2024-03-01T10:02:00Z,500")
+	assertStringEquals(lines[4], "bad,200")
+
+	// the negative index is from the end
+	lines = execCmdGetLines(`printf 'status,ts\n500,1709287212\n' | csv split:csv 'bucket[-1,5m]'`)
+	assertStringEquals(lines[1], "500,2024-03-01T10:00:00Z")
+
+	lines = execCmdGetError(data + " | csv 'bucket[0,soon]'")
+	assertStringEquals(lines[0][20:], "Invalid interval 'soon' in 'bucket[0,soon]'. The interval is a duration eg. 30s, 5m, 1h, 1d")
+}
@@ -63,7 +63,7 @@ func TestJsonCount(t *testing.T) {
 	lines := execCmdGetLines(cmd)
 	assertIntEquals(len(lines), 7)
 	assertStringEquals(lines[0], "name                     ns        hostIp          podIp           count    ")
-	assertStringEquals(lines[1], "storefront-cd75b46c7     sample    192.168.1.60    10.1.151.233    3        ")
+	assertStringEquals(lines[1], "storefront-cd75b46c7     sample    192.168.1.60    10.1.151.233        3    ")
 	assertStringEquals(lines[2], "                                                   10.1.151.234             ")
 
 	cmd = fmt.Sprintf("cat %v | jp keys[items.metadata.name,items.metadata.namespace,items.status.hostIP,items.status.podIP] out..table head[name,ns,hostIp,podIp] | csv row[1:] group[0]:count out..csv tr..c0..split:-..merge:-..ncol[-1] sort[4]:desc", podsJson)
@@ -141,6 +141,17 @@ func execCmd(cmd string) []byte {
 	return out
 }
 
+// execCmdGetError returns the stderr lines of the command, the command is expected to fail
+func execCmdGetError(cmd string) []string {
+	command := exec.Command("bash", "-c", cmd)
+	var stderr strings.Builder
+	command.Stderr = &stderr
+	if err := command.Run(); err == nil {
+		log.Fatalf("Expected the command to fail: %s", cmd)
+	}
+	return strings.Split(stderr.String(), "\n")
+}
+
 func getCurrentDir(t *testing.T) string {
 	_, filename, _, _ := runtime.Caller(0)
 	//t.Logf("Current test filename: %s", filename)
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/abeytom/utilbox/pipeline"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(bytes []byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestPipelineText(t *testing.T) {
+	file, err := os.Open(path.Join(getCurrentDir(t), "topics.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var out bytes.Buffer
+	p := pipeline.Pipeline{
+		Source: pipeline.TextSource(file),
+		Stages: []pipeline.Stage{
+			pipeline.Cols{Cols: "TOPIC,PARTITION,LAG"},
+			pipeline.Filter{Expr: "[PARTITION] > 5"},
+			pipeline.Group{Cols: "TOPIC", Aggs: "PARTITION=max", Count: true},
+			pipeline.Sort{Cols: "count", Desc: true},
+			pipeline.Limit(2),
+		},
+		Sink: pipeline.Sink{Writer: &out, Format: pipeline.Jsonl},
+	}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], `{"TOPIC":"topic1","PARTITION":51,"LAG":0,"count":8}`)
+	assertStringEquals(lines[1], `{"TOPIC":"topic2","PARTITION":7,"LAG":0,"count":2}`)
+}
+
+func TestPipelineCsvAndYaml(t *testing.T) {
+	var out bytes.Buffer
+	p := pipeline.Pipeline{
+		Source: pipeline.CsvSource(strings.NewReader("a,b\n1,2\n3,4\n")),
+		Stages: []pipeline.Stage{pipeline.Calc{Expr: "[a]+[b]"}},
+		Sink:   pipeline.Sink{Writer: &out, Format: pipeline.Markdown},
+	}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	assertStringEquals(lines[0], "| a   | b   | a+b |")
+	assertStringEquals(lines[3], "| 3   | 4   | 7   |")
+
+	file, err := os.Open(path.Join(getCurrentDir(t), "pods.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	out.Reset()
+	p = pipeline.Pipeline{
+		Source: pipeline.YamlSource(file, "items.metadata.name", "items.status.phase"),
+		Sink:   pipeline.Sink{Writer: &out, Format: pipeline.Csv},
+	}
+	if err := p.Run(); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(out.String(), "\n")
+	assertIntEquals(len(lines), 8)
+	assertStringEquals(lines[0], "items.metadata.name,items.status.phase")
+	assertStringEquals(lines[1], "gateway-7b8c56d867-brgg7,Running")
+}
+
+func TestPipelineErrors(t *testing.T) {
+	newPipeline := func(stage pipeline.Stage) pipeline.Pipeline {
+		return pipeline.Pipeline{
+			Source: pipeline.CsvSource(strings.NewReader("a,b\n1,2\n")),
+			Stages: []pipeline.Stage{stage},
+			Sink:   pipeline.Sink{Writer: &bytes.Buffer{}},
+		}
+	}
+	p := newPipeline(pipeline.Top{Count: 1, By: "c"})
+	assertStringEquals(p.Run().Error(), "Invalid top by, unknown column 'c'. The columns are [a b]")
+
+	p = newPipeline(pipeline.Flags{"pivot[col=1]"})
+	assertStringEquals(p.Run().Error(), "Invalid pivot 'pivot[col=1]'. The row and col are required")
+
+	p = newPipeline(pipeline.Cols{Cols: "0"})
+	p.Sink.Writer = failingWriter{}
+	assertStringEquals(p.Run().Error(), "closed")
+
+	p = newPipeline(pipeline.Limit(1))
+	p.Stages = append(p.Stages, pipeline.Sort{Cols: "a"})
+	assertStringEquals(p.Run().Error(), "The stage pipeline.Sort is after pipeline.Limit. The stages are applied in the "+
+		"order Rows, Cols, Types, Bucket, Filter, Group, Stats, Having, Calc, Sort, Uniq, Top, Limit, Tail")
+
+	p = newPipeline(pipeline.Group{Cols: "a", Count: true})
+	p.Stages = append(p.Stages, pipeline.Filter{Expr: "[count] > 1"})
+	assertStringEquals(p.Run().Error(), "The stage pipeline.Filter is after pipeline.Group. The stages are applied in "+
+		"the order Rows, Cols, Types, Bucket, Filter, Group, Stats, Having, Calc, Sort, Uniq, Top, Limit, Tail. The "+
+		"groups are filtered by the Having")
+
+	// the fields are joined into the flags, those cannot have the delimiters
+	p = newPipeline(pipeline.Filter{Expr: `[a] == "x..y"`})
+	assertStringEquals(p.Run().Error(), `The pipeline.Filter Expr '[a] == "x..y"' cannot have '..', it is a delimiter of the flag`)
+
+	p = newPipeline(pipeline.Having{Expr: ".5 < [b]"})
+	assertStringEquals(p.Run().Error(), "The pipeline.Having Expr '.5 < [b]' cannot start with '.', it is a delimiter of the flag")
+
+	p = newPipeline(pipeline.Group{Cols: "a", Aggs: "b]=sum"})
+	assertStringEquals(p.Run().Error(), "The pipeline.Group Aggs 'b]=sum' cannot have ']', it is a delimiter of the flag")
+
+	p = newPipeline(pipeline.Headers{"x", "y,z"})
+	assertStringEquals(p.Run().Error(), "The pipeline.Headers header 'y,z' cannot have ',', it is a delimiter of the flag")
+
+	p = newPipeline(pipeline.Cols{Cols: "0"})
+	p.Source.Type = "xml"
+	assertStringEquals(p.Run().Error(), "Unknown source type 'xml'. The types are text, csv, columns, json and yaml")
+}
+
+// the regex functions of the expressions share the cache of the patterns
+func TestPipelineConcurrentRuns(t *testing.T) {
+	errs := make(chan error, 8)
+	outs := make([]bytes.Buffer, 8)
+	for i := range outs {
+		go func(i int) {
+			p := pipeline.Pipeline{
+				Source: pipeline.CsvSource(strings.NewReader("a\nv1-x\nv22-y\n")),
+				Stages: []pipeline.Stage{pipeline.Calc{Expr: fmt.Sprintf(`regex_extract([a],"v(\\d+)%v")`, strings.Repeat(".?", i))}},
+				Sink:   pipeline.Sink{Writer: &outs[i], Format: pipeline.Csv},
+			}
+			errs <- p.Run()
+		}(i)
+	}
+	for range outs {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range outs {
+		lines := strings.Split(outs[i].String(), "\n")
+		assertStringEquals(lines[2], "v22-y,22")
+	}
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"fmt"
+	"path"
+	"testing"
+)
+
+func TestRegexExtract(t *testing.T) {
+	fpath := path.Join(getCurrentDir(t), "topics.txt")
+
+	// the groups without names are written as is, without the headers
+	cmd := fmt.Sprintf(`cat %v | regex '(topic\d) +(\d+)' 'consumer-(\d+)'`, fpath)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 18)
+	assertStringEquals(lines[0], "topic1,44")
+
+	cmd = fmt.Sprintf(`cat %v | regex '(?P<topic>topic\d) +(?P<part>\d+)' 'group[topic]:count' 'sort[count]:desc' 'filter..[part] > 0' out..jsonl`, fpath)
+	lines = execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], `{"topic":"topic1","part":380,"count":8}`)
+	assertStringEquals(lines[1], `{"topic":"topic2","part":28,"count":7}`)
+
+	lines = execCmdGetLines(`printf 'k=1 j=2\nnone\nz=9\n' | regex all '(?P<key>\w)=(?P<val>\d)'`)
+	assertIntEquals(len(lines), 5)
+	assertStringEquals(lines[0], "key,val")
+	assertStringEquals(lines[2], "j,2")
+
+	lines = execCmdGetLines(`printf 'k=1 j=2\nnone\n' | regex unmatched:flag '(?P<key>\w)=(?P<val>\d)' 'filter..[matched] == "false"'`)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[0], "key,val,matched")
+	assertStringEquals(lines[1], "none,,false")
+
+	lines = execCmdGetLines(`printf 'k=1 j=2\nnone\n' | regex unmatched:pass '(\w)=(\d)'`)
+	assertIntEquals(len(lines), 3)
+	assertStringEquals(lines[1], "none,")
+
+	// the regex which starts with the name of a csv flag
+	lines = execCmdGetLines(`printf 'tail:12 x\nhead:3\n' | regex 'tail:(\d+)'`)
+	assertIntEquals(len(lines), 2)
+	assertStringEquals(lines[0], "12")
+	lines = execCmdGetLines(`printf 'limit:7\nlimit:8\n' | regex 'limit:(\d+)' limit:1`)
+	assertIntEquals(len(lines), 2)
+	assertStringEquals(lines[0], "7")
+	lines = execCmdGetLines(`printf 'col12b\n' | regex 'col[0-9]+(b)' 'split:(\w+)' 'calc([0]+"x")'`)
+	assertStringEquals(lines[0], "b,bx")
+}
@@ -58,7 +58,7 @@ func TestYamlCount(t *testing.T) {
 	lines := execCmdGetLines(cmd)
 	assertIntEquals(len(lines), 7)
 	assertStringEquals(lines[0], "name                     ns        hostIp          podIp           count    ")
-	assertStringEquals(lines[1], "storefront-cd75b46c7     sample    192.168.1.60    10.1.151.233    3        ")
+	assertStringEquals(lines[1], "storefront-cd75b46c7     sample    192.168.1.60    10.1.151.233        3    ")
 	assertStringEquals(lines[2], "                                                   10.1.151.234             ")
 
 	cmd = fmt.Sprintf("cat %v | yp keys[items.metadata.name,items.metadata.namespace,items.status.hostIP,items.status.podIP] out..table head[name,ns,hostIp,podIp] | csv row[1:] group[0]:count out..csv tr..c0..split:-..merge:-..ncol[-1] sort[4]:desc", podsJson)
@@ -102,3 +102,13 @@ func TestYamlMultipleKeyBranch(t *testing.T) {
 	assertStringEquals(lines[1], "pod1         container1              ")
 	assertStringEquals(lines[2], "             container2              ")
 }
+
+func TestYamlStats(t *testing.T) {
+	podsYaml := path.Join(getCurrentDir(t), "pods.yml")
+
+	cmd := fmt.Sprintf("cat %v | yp stats keys[items.status.phase,items.status.containerStatuses.restartCount] out..csv", podsYaml)
+	lines := execCmdGetLines(cmd)
+	assertIntEquals(len(lines), 4)
+	assertStringEquals(lines[1], "items.status.phase,string,6,0,1,,,,,Running (6),")
+	assertStringEquals(lines[2], "items.status.containerStatuses.restartCount,int,6,0,1,0,0,0,0,0 (6),█")
+}
@@ -145,6 +145,8 @@ func awsAmiList(args []string, ec2 ec2Params) {
 		log.Fatalf("The `amiTypeTag` must be set")
 	}
 	raw := len(args) > 2 && args[2] == "--raw"
+	defer exitOnError()
+	csvFmt := parseListArgs(args[2:])
 	outStr, errStr, err := ExecuteCommand2("aws", "ec2", "describe-images", "--filters",
 		"Name=tag:Type,Values="+ec2.AmiTypeTag)
 	if err != nil {
@@ -176,17 +178,6 @@ func awsAmiList(args []string, ec2 ec2Params) {
 		}
 		rows = append(rows, row)
 	}
-	csvFmt := &CsvFormat{
-		ColExt:      &common.IntRange{},
-		RowExt:      &common.IntRange{},
-		Split:       "space+",
-		Merge:       " ",
-		LMerge:      ",",
-		Wrap:        "",
-		IsLMerge:    false,
-		NoHeaderOut: false,
-		OutputDef:   &OutputDef{Type: "table"},
-	}
 	processOutput(csvFmt, &DataRows{
 		DataRows:     rows,
 		Headers:      []string{"SL", "AMI", "NAME", "STATE", "VIRT", "TAGS"},
@@ -298,6 +289,8 @@ func awsList(args []string, param ec2Params) {
 		log.Fatalf("The instanceTypeTag must be set")
 	}
 	raw := len(args) > 1 && args[1] == "--raw"
+	defer exitOnError()
+	csvFmt := parseListArgs(args[1:])
 	outStr, errStr, err := ExecuteCommand2("aws", "ec2", "describe-instances", "--filters",
 		"Name=tag:Type,Values="+param.InstanceTypeTag)
 	if err != nil {
@@ -337,6 +330,25 @@ func awsList(args []string, param ec2Params) {
 		rows = append(rows, row)
 	}
 
+	processOutput(csvFmt, &DataRows{
+		DataRows:     rows,
+		Headers:      []string{"SL", "INSTANCE", "TYPE", "STATE", "HOSTNAME", "PUBLIC_IP", "TAGS", "LAUNCHED_AT"},
+		GroupByCount: 0,
+		Converted:    false,
+	})
+}
+
+// parseListArgs parses the csv flags of the ls commands eg. awx ls sort[2] out..md, the default output is the table.
+// The --raw and the --ec2-* options are skipped
+func parseListArgs(args []string) *CsvFormat {
+	var csvArgs []string
+	for i := 0; i < len(args); i++ {
+		if strings.Index(args[i], "--ec2-") == 0 {
+			i++
+		} else if args[i] != "--raw" {
+			csvArgs = append(csvArgs, args[i])
+		}
+	}
 	csvFmt := &CsvFormat{
 		ColExt:      &common.IntRange{},
 		RowExt:      &common.IntRange{},
@@ -348,12 +360,7 @@ func awsList(args []string, param ec2Params) {
 		NoHeaderOut: false,
 		OutputDef:   &OutputDef{Type: "table"},
 	}
-	processOutput(csvFmt, &DataRows{
-		DataRows:     rows,
-		Headers:      []string{"SL", "INSTANCE", "TYPE", "STATE", "HOSTNAME", "PUBLIC_IP", "TAGS", "LAUNCHED_AT"},
-		GroupByCount: 0,
-		Converted:    false,
-	})
+	return doParseCsvArgs(csvArgs, csvFmt)
 }
 
 func getSortedInstances(desc awsDescribeInst) []awsInstance {
@@ -413,8 +420,8 @@ func timeSince(t time.Time) string {
 
 func awsHelp() {
 	fmt.Println("Available commands are:")
-	fmt.Println("    awx ls [--raw]")
-	fmt.Println("    aws ami ls [--raw]")
+	fmt.Println("    awx ls [--raw] [csv flags eg. sort[2] out..md]")
+	fmt.Println("    aws ami ls [--raw] [csv flags eg. sort[2] out..md]")
 	fmt.Println("    awx launch <instanceId> <name suffix>")
 	fmt.Println("    awx start <instanceId>")
 	fmt.Println("    awx stop <instanceId>")
@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"github.com/abeytom/utilbox/common"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aggregator accumulates the values of one column within a group
+type Aggregator interface {
+	Add(val string)
+	Result() interface{}
+}
+
+type AggDef struct {
+	Cols *common.IntRange
+	Func string
+}
+
+/*
+	agg[2=max,3=avg,4=p95,5=distinct,6=first]
+	agg[2-5=sum]
+	agg[RESTARTS=max]
+*/
+func extractAggDefs(arg string) []AggDef {
+	var aggs []AggDef
+	for _, part := range common.ParseIndexStr(arg) {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			fatalf("Invalid aggregate '%v'. The format is <col>=<func>", part)
+		}
+		fn := strings.TrimSpace(kv[1])
+		if !isValidAggFunc(fn) {
+			fatalf("Unknown aggregate function '%v'", fn)
+		}
+		aggs = append(aggs, AggDef{Cols: common.ParseRange(strings.TrimSpace(kv[0])), Func: fn})
+	}
+	return aggs
+}
+
+// resolveAggDefs maps the column indices to the aggregate functions
+func resolveAggDefs(aggDefs []AggDef, headers []string) map[int]string {
+	aggs := make(map[int]string)
+	for _, def := range aggDefs {
+		resolveRange(def.Cols, headers, "agg")
+		for _, index := range def.Cols.Indices {
+			aggs[index] = def.Func
+		}
+	}
+	return aggs
+}
+
+func isValidAggFunc(fn string) bool {
+	switch fn {
+	case "sum", "min", "max", "avg", "median", "count", "distinct", "first", "last", "join":
+		return true
+	}
+	_, ok := parsePercentile(fn)
+	return ok
+}
+
+// p95 => 95, p99.9 => 99.9
+func parsePercentile(fn string) (float64, bool) {
+	if len(fn) < 2 || fn[0] != 'p' {
+		return 0, false
+	}
+	pct, err := strconv.ParseFloat(fn[1:], 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, false
+	}
+	return pct, true
+}
+
+// NewAggregator creates the aggregator for the function. The values are converted based on the type, if any
+func NewAggregator(fn string, typ string) Aggregator {
+	switch fn {
+	case "sum":
+		return &sumAgg{typed: typed{typ}}
+	case "min":
+		return &minMaxAgg{typed: typed{typ}, max: false}
+	case "max":
+		return &minMaxAgg{typed: typed{typ}, max: true}
+	case "avg":
+		return &avgAgg{typed: typed{typ}}
+	case "median":
+		return &percentileAgg{typed: typed{typ}, pct: 50}
+	case "count":
+		return &countAgg{}
+	case "distinct":
+		return &distinctAgg{values: make(map[string]struct{})}
+	case "first":
+		return &firstLastAgg{typed: typed{typ}, last: false}
+	case "last":
+		return &firstLastAgg{typed: typed{typ}, last: true}
+	case "join":
+		return &joinAgg{values: common.NewStringList()}
+	}
+	if pct, ok := parsePercentile(fn); ok {
+		return &percentileAgg{typed: typed{typ}, pct: pct}
+	}
+	return nil
+}
+
+// typed converts the values based on the type set by types[]. The type is inferred if it is empty
+type typed struct {
+	typ string
+}
+
+func (t typed) convert(val string) interface{} {
+	return ConvertTyped(val, t.typ)
+}
+
+// the result of the quantities is a quantity of the same type
+func (t typed) result(value float64, qty *Quantity) interface{} {
+	if qty != nil {
+		return qty.computed(value)
+	}
+	return value
+}
+
+type sumAgg struct {
+	typed
+	intSum   int64
+	floatSum float64
+	isFloat  bool
+	qty      *Quantity
+}
+
+func (a *sumAgg) Add(val string) {
+	switch num := a.convert(val).(type) {
+	case int64:
+		a.intSum += num
+	case float64:
+		a.floatSum += num
+		a.isFloat = true
+	case Quantity:
+		a.floatSum += num.Value
+		if a.qty == nil {
+			a.qty = &num
+		}
+	}
+}
+
+func (a *sumAgg) Result() interface{} {
+	if a.qty != nil {
+		return a.qty.computed(a.floatSum + float64(a.intSum))
+	}
+	if a.isFloat {
+		return a.floatSum + float64(a.intSum)
+	}
+	return a.intSum
+}
+
+type minMaxAgg struct {
+	typed
+	value interface{}
+	max   bool
+}
+
+func (a *minMaxAgg) Add(val string) {
+	if val == "" {
+		return
+	}
+	nVal := a.convert(val)
+	if a.value == nil {
+		a.value = nVal
+		return
+	}
+	compare := compareValues(nVal, a.value)
+	if (a.max && compare > 0) || (!a.max && compare < 0) {
+		a.value = nVal
+	}
+}
+
+func (a *minMaxAgg) Result() interface{} {
+	if a.value == nil {
+		return ""
+	}
+	return a.value
+}
+
+type avgAgg struct {
+	typed
+	sum   float64
+	count int
+	qty   *Quantity
+}
+
+func (a *avgAgg) Add(val string) {
+	nVal := a.convert(val)
+	if num, ok := toFloat64(nVal); ok {
+		a.sum += num
+		a.count++
+		if qty, isQty := nVal.(Quantity); isQty && a.qty == nil {
+			a.qty = &qty
+		}
+	}
+}
+
+func (a *avgAgg) Result() interface{} {
+	if a.count == 0 {
+		return ""
+	}
+	return a.result(a.sum/float64(a.count), a.qty)
+}
+
+type percentileAgg struct {
+	typed
+	values []float64
+	pct    float64
+	qty    *Quantity
+}
+
+func (a *percentileAgg) Add(val string) {
+	nVal := a.convert(val)
+	if num, ok := toFloat64(nVal); ok {
+		a.values = append(a.values, num)
+		if qty, isQty := nVal.(Quantity); isQty && a.qty == nil {
+			a.qty = &qty
+		}
+	}
+}
+
+// linear interpolation between the closest ranks
+func (a *percentileAgg) Result() interface{} {
+	size := len(a.values)
+	if size == 0 {
+		return ""
+	}
+	sort.Float64s(a.values)
+	rank := a.pct / 100 * float64(size-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return a.result(a.values[lower], a.qty)
+	}
+	return a.result(a.values[lower]+(a.values[upper]-a.values[lower])*(rank-float64(lower)), a.qty)
+}
+
+type countAgg struct {
+	count int64
+}
+
+func (a *countAgg) Add(val string) {
+	if val != "" {
+		a.count++
+	}
+}
+
+func (a *countAgg) Result() interface{} {
+	return a.count
+}
+
+type distinctAgg struct {
+	values map[string]struct{}
+}
+
+func (a *distinctAgg) Add(val string) {
+	if val != "" {
+		a.values[val] = struct{}{}
+	}
+}
+
+func (a *distinctAgg) Result() interface{} {
+	return int64(len(a.values))
+}
+
+type firstLastAgg struct {
+	typed
+	value *string
+	last  bool
+}
+
+func (a *firstLastAgg) Add(val string) {
+	if a.value == nil || a.last {
+		a.value = &val
+	}
+}
+
+func (a *firstLastAgg) Result() interface{} {
+	if a.value == nil {
+		return ""
+	}
+	return a.convert(*a.value)
+}
+
+type joinAgg struct {
+	values *common.StringList
+}
+
+func (a *joinAgg) Add(val string) {
+	if val != "" {
+		a.values.Add(val)
+	}
+}
+
+func (a *joinAgg) Result() interface{} {
+	return a.values
+}
+
+func toFloat64(val interface{}) (float64, bool) {
+	switch val.(type) {
+	case int64:
+		return float64(val.(int64)), true
+	case float64:
+		return val.(float64), true
+	case Quantity:
+		return val.(Quantity).Value, true
+	default:
+		return 0, false
+	}
+}
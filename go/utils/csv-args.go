@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type flagUsage struct {
+	Name    string
+	Usage   string
+	Bracket bool // the flag is followed by the [..] eg. col[0,2]
+	Value   bool // the flag needs a value eg. out..table, limit:10
+}
+
+var flagUsages = []flagUsage{
+	{Name: "row", Usage: "row[1:]", Bracket: true},
+	{Name: "col", Usage: "col[0,2]", Bracket: true},
+	{Name: "ncol", Usage: "ncol[5]", Bracket: true},
+	{Name: "split", Usage: "split:comma", Value: true},
+	{Name: "merge", Usage: "merge:pipe", Value: true},
+	{Name: "lmerge", Usage: "lmerge:comma"},
+	{Name: "wrap", Usage: "wrap:quote", Value: true},
+	{Name: "tr", Usage: "tr..c0..split:/..col[-1]", Value: true},
+	{Name: "group", Usage: "group[0]:count", Bracket: true},
+	{Name: "calc", Usage: "calc([2]+[3])", Value: true},
+	{Name: "sort", Usage: "sort[1]:desc", Bracket: true},
+	{Name: "mem", Usage: "mem:512M", Value: true},
+	{Name: "head", Usage: "head[name,count]", Bracket: true},
+	{Name: "-inhead", Usage: "-inhead"},
+	{Name: "-outhead", Usage: "-outhead"},
+	{Name: "out", Usage: "out..table", Value: true},
+	{Name: "filter", Usage: "filter..[2] > 0", Value: true},
+	{Name: "having", Usage: "having..[count] > 1", Value: true},
+	{Name: "keys", Usage: "keys[metadata.name]"},
+	{Name: "join", Usage: "join[owners.csv,left=0]", Bracket: true},
+	{Name: "pivot", Usage: "pivot[row=0,col=1,val=4]", Bracket: true},
+	{Name: "unpivot", Usage: "unpivot[1,2]", Bracket: true},
+	{Name: "win", Usage: "win..rank[2]", Value: true},
+	{Name: "diff", Usage: "diff..prev.csv..key[0]", Value: true},
+	{Name: "uniq", Usage: "uniq[0]:count"},
+	{Name: "top", Usage: "top[3,by=4]", Bracket: true},
+	{Name: "limit", Usage: "limit:10", Value: true},
+	{Name: "tail", Usage: "tail:10", Value: true},
+	{Name: "types", Usage: "types[AGE=duration]", Bracket: true},
+	{Name: "stats", Usage: "stats"},
+	{Name: "bucket", Usage: "bucket[0,5m]", Bracket: true},
+}
+
+var flagNameRegex = regexp.MustCompile(`^-?[a-zA-Z]+`)
+
+// validateFlag checks the name of the flag and the brackets. The unknown flags are not ignored
+func validateFlag(arg string) {
+	name := flagNameRegex.FindString(arg)
+	usage := findFlagUsage(name)
+	if usage == nil {
+		var names []string
+		for _, usage := range flagUsages {
+			names = append(names, usage.Name)
+		}
+		if match := findFlagUsage(common.ClosestMatch(name, names)); match != nil {
+			fatalf("Unknown flag '%v', did you mean '%v'? eg. %v", arg, match.Name, match.Usage)
+		}
+		fatalf("Unknown flag '%v'. The flags are %v", arg, strings.Join(names, ", "))
+	}
+	if usage.Value && arg == name {
+		argError(arg, len(arg), "Missing the value of %v eg. %v", name, usage.Usage)
+	}
+	hasBracket := strings.HasPrefix(arg[len(name):], "[")
+	if usage.Bracket && !hasBracket {
+		argError(arg, len(name), "Expected '[' after %v eg. %v", name, usage.Usage)
+	}
+	if hasBracket && !strings.Contains(arg, "]") {
+		argError(arg, len(arg), "Missing ']' in '%v'", arg)
+	}
+}
+
+func findFlagUsage(name string) *flagUsage {
+	for i := range flagUsages {
+		if flagUsages[i].Name == name {
+			return &flagUsages[i]
+		}
+	}
+	return nil
+}
+
+/*
+	validateIndexStr checks the column indices and the names of the [..] at the offset of the arg
+	col[0,1:3:4] => the marker is at the second ':'
+*/
+func validateIndexStr(arg string, offset int, str string) {
+	if strings.TrimSpace(str) == "" {
+		return
+	}
+	for _, part := range strings.Split(str, ",") {
+		trimmed := strings.TrimLeft(part, " ")
+		pos := offset + len(part) - len(trimmed)
+		trimmed = strings.TrimRight(trimmed, " ")
+		if trimmed == "" {
+			argError(arg, pos, "Missing the column in '%v'", arg)
+		}
+		if !common.IsColumnName(trimmed) {
+			if invalid := common.InvalidRangePos(trimmed); invalid >= 0 {
+				argError(arg, pos+invalid, "Invalid column '%v' in '%v'", trimmed, arg)
+			}
+		}
+		offset += len(part) + 1
+	}
+}
+
+// argError shows the arg with a marker at the position of the invalid char
+func argError(arg string, pos int, format string, args ...interface{}) {
+	marker := strings.Repeat(" ", len([]rune(arg[:pos]))) + "^"
+	fatalf("%v\n    %v\n    %v", fmt.Sprintf(format, args...), arg, marker)
+}
+
+// checkIndices checks that the column indices, if any, are within the columns. The negative indices are from the end
+func checkIndices(r *common.IntRange, count int, flag string) {
+	if r == nil || r.HasNames() || count == 0 {
+		return
+	}
+	for _, index := range r.Indices {
+		if index >= count || index < -count {
+			fatalf("Invalid %v, the column %v does not exist. There are %v columns", flag, index, count)
+		}
+	}
+}
+
+// the scopes of the column references. The columns are checked once the headers of the scope are known
+const (
+	inputScope  = "input"  // col, ncol and tr are applied on the columns of the input
+	dataScope   = "data"   // filter and bucket are applied after the col, the tr and the join
+	havingScope = "having" // having is applied on the groups
+	calcScope   = "calc"   // calc is applied on the output rows, a calc can refer to the preceding calc
+)
+
+/*
+	colRef is a column index in an arg. The Pos is the position of the index in the arg, the Extra is the number of
+	the columns added before the arg eg. the preceding calc. The End of a range is exclusive eg. col[2:8]
+*/
+type colRef struct {
+	Scope string
+	Flag  string
+	Arg   string
+	Pos   int
+	Index int
+	Extra int
+	End   bool
+}
+
+var exprIndexRegex = regexp.MustCompile(`\[(-?\d+)\]`)
+
+// bracketRefs returns the column indices of the [..] of the arg eg. col[0,2:4]. The last skip values are not columns
+func bracketRefs(scope string, flag string, arg string, skip int) []colRef {
+	start := strings.Index(arg, "[")
+	end := strings.LastIndex(arg, "]")
+	if start < 0 || end < start {
+		return nil
+	}
+	var refs []colRef
+	parts := strings.Split(arg[start+1:end], ",")
+	pos := start + 1
+	for i, part := range parts[:MaxInt(len(parts)-skip, 0)] {
+		if i > 0 {
+			pos += len(parts[i-1]) + 1
+		}
+		piecePos := pos
+		for j, piece := range strings.Split(part, ":") {
+			trimmed := strings.TrimLeft(piece, " ")
+			if index, err := strconv.Atoi(strings.TrimSpace(piece)); err == nil {
+				refs = append(refs, colRef{Scope: scope, Flag: flag, Arg: arg, Pos: piecePos + len(piece) - len(trimmed),
+					Index: index, End: j > 0})
+			}
+			piecePos += len(piece) + 1
+		}
+	}
+	return refs
+}
+
+// exprRefs returns the column indices of the expression eg. filter..[2] > 0
+func exprRefs(scope string, flag string, arg string, extra int) []colRef {
+	var refs []colRef
+	for _, match := range exprIndexRegex.FindAllStringSubmatchIndex(arg, -1) {
+		index, _ := strconv.Atoi(arg[match[2]:match[3]])
+		refs = append(refs, colRef{Scope: scope, Flag: flag, Arg: arg, Pos: match[2], Index: index, Extra: extra})
+	}
+	return refs
+}
+
+// checkColRefs checks that the column indices of the scope are within the columns. The negative indices are from the end
+func checkColRefs(csvFmt *CsvFormat, scope string, count int) {
+	for _, ref := range csvFmt.ColRefs {
+		if ref.Scope != scope {
+			continue
+		}
+		max := count + ref.Extra
+		if ref.Index < -max || ref.Index > max || (ref.Index == max && !ref.End) {
+			argError(ref.Arg, ref.Pos, "Invalid %v, the column %v does not exist. There are %v columns", ref.Flag,
+				ref.Index, max)
+		}
+	}
+}
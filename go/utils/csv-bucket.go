@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"github.com/abeytom/utilbox/common"
+	"strings"
+	"time"
+)
+
+// BucketDef truncates the times of the Cols to the Interval, the start of the bucket is the value
+type BucketDef struct {
+	Cols     *common.IntRange
+	Interval time.Duration
+}
+
+/*
+	bucket[0,5m]
+	bucket[@timestamp,1h]
+	bucket[0,2,1d]       => the last is the interval, the rest are the columns
+*/
+func extractBucketDef(arg string) *BucketDef {
+	positional, opts := parseIndexOptions(arg)
+	if len(positional) < 2 || len(opts) > 0 {
+		fatalf("Invalid bucket '%v'. The format is bucket[<col>,<interval>] eg. bucket[0,5m]", arg)
+	}
+	intervalStr := positional[len(positional)-1]
+	interval := common.ParseDuration(intervalStr)
+	if !common.IsDuration(intervalStr) || interval == nil || *interval <= 0 {
+		fatalf("Invalid interval '%v' in '%v'. The interval is a duration eg. 30s, 5m, 1h, 1d", intervalStr, arg)
+	}
+	return &BucketDef{
+		Cols:     extractCsvIndexArg("[" + strings.Join(positional[:len(positional)-1], ",") + "]"),
+		Interval: *interval,
+	}
+}
+
+/*
+	applyBucket replaces the times of the columns with the start of the bucket in UTC eg. 2021-06-01T10:35:00Z. The
+	values which are not times are retained as is
+*/
+func applyBucket(def *BucketDef, words []string) {
+	for _, index := range def.Cols.Indices {
+		if index < 0 {
+			index = len(words) + index
+		}
+		if index < 0 || index >= len(words) {
+			continue
+		}
+		t, err := common.ParseTime(words[index])
+		if err != nil {
+			continue
+		}
+		start := t.UTC().Truncate(def.Interval)
+		if def.Interval < time.Second {
+			words[index] = start.Format(time.RFC3339Nano)
+		} else {
+			words[index] = start.Format(time.RFC3339)
+		}
+	}
+}
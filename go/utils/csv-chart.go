@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const defaultHistBins = 10
+
+// the partial blocks of the bars, the 1/8 to the 7/8 of a full block
+var barEighths = []rune("▏▎▍▌▋▊▉")
+
+// chartBar is a bar of the chart, the Value is the length and the Text is shown after the bar
+type chartBar struct {
+	Label string
+	Value float64
+	Text  string
+}
+
+/*
+	processBarOutput writes a horizontal bar for each row. The value is the last numeric column, the label is the keys
+	of the group eg. group[0]:count, or the non numeric columns before the value. The bars are scaled to the width of
+	the terminal
+*/
+func processBarOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, groupByCount int) {
+	if len(rows) == 0 {
+		return
+	}
+	valueCol := lastNumericCol(csvFmt, rows)
+	labelCols := chartLabelCols(csvFmt, rows, valueCol, groupByCount)
+	bars := make([]chartBar, 0, len(rows))
+	for _, row := range rows {
+		var labels []string
+		for _, col := range labelCols {
+			if col < len(row.Cols) {
+				labels = append(labels, cellString(row.Cols[col]))
+			}
+		}
+		bar := chartBar{Label: strings.Join(labels, " ")}
+		if valueCol < len(row.Cols) {
+			bar.Value, _ = numericValue(convertCol(csvFmt, valueCol, row.Cols[valueCol]))
+			bar.Text = cellString(row.Cols[valueCol])
+		}
+		bars = append(bars, bar)
+	}
+	var labelHeaders []string
+	for _, col := range labelCols {
+		labelHeaders = append(labelHeaders, outputHeader(headers, col))
+	}
+	writeBars(csvFmt, bars, strings.Join(labelHeaders, " "), outputHeader(headers, valueCol))
+}
+
+/*
+	processHistOutput writes the counts of the values of the last numeric column in the equal width bins between the
+	min and the max, a bar for each bin. The number of bins is set by out..hist..bins:20
+*/
+func processHistOutput(rows []DataRow, csvFmt *CsvFormat, headers []string) {
+	if len(rows) == 0 {
+		return
+	}
+	valueCol := lastNumericCol(csvFmt, rows)
+	var values []float64
+	var qty Quantity
+	for _, row := range rows {
+		if valueCol >= len(row.Cols) {
+			continue
+		}
+		value := convertCol(csvFmt, valueCol, row.Cols[valueCol])
+		if number, ok := numericValue(value); ok {
+			values = append(values, number)
+			if q, isQty := value.(Quantity); isQty {
+				qty = q.computed(0)
+			}
+		}
+	}
+	bins := defaultHistBins
+	if csvFmt.OutputDef.Bins > 0 {
+		bins = csvFmt.OutputDef.Bins
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+	}
+	if min == max {
+		bins = 1
+	}
+	width := (max - min) / float64(bins)
+	counts := make([]int, bins)
+	for _, value := range values {
+		counts[histogramBin(value, min, width, bins)]++
+	}
+	bars := make([]chartBar, bins)
+	for i, count := range counts {
+		label := histBound(qty, min+float64(i)*width)
+		if bins > 1 {
+			label += " - " + histBound(qty, min+float64(i+1)*width)
+		}
+		bars[i] = chartBar{Label: label, Value: float64(count), Text: strconv.Itoa(count)}
+	}
+	writeBars(csvFmt, bars, outputHeader(headers, valueCol), "count")
+}
+
+// histBound formats the bound of a bin same as the values, the quantities in the units of the type
+func histBound(qty Quantity, value float64) string {
+	if qty.Type == "" {
+		return formatNumber(value)
+	}
+	return qty.computed(value).String()
+}
+
+// histogramBin returns the index of the equal width bin of the value, the max is in the last bin
+func histogramBin(value float64, min float64, width float64, bins int) int {
+	if width <= 0 {
+		return 0
+	}
+	bin := int((value - min) / width)
+	if bin >= bins {
+		bin = bins - 1
+	}
+	if bin < 0 {
+		bin = 0
+	}
+	return bin
+}
+
+// lastNumericCol returns the index of the last column with numbers, the empty values are skipped
+func lastNumericCol(csvFmt *CsvFormat, rows []DataRow) int {
+	colCount := 0
+	for _, row := range rows {
+		colCount = MaxInt(colCount, len(row.Cols))
+	}
+	for col := colCount - 1; col >= 0; col-- {
+		if isNumericCol(csvFmt, rows, col) {
+			return col
+		}
+	}
+	fatalf("The out..%v needs a numeric column eg. group[0]:count out..%v", csvFmt.OutputDef.Type,
+		csvFmt.OutputDef.Type)
+	return -1
+}
+
+func isNumericCol(csvFmt *CsvFormat, rows []DataRow, col int) bool {
+	numbers := 0
+	for _, row := range rows {
+		if col >= len(row.Cols) || cellString(row.Cols[col]) == "" {
+			continue
+		}
+		if _, ok := numericValue(convertCol(csvFmt, col, row.Cols[col])); !ok {
+			return false
+		}
+		numbers++
+	}
+	return numbers > 0
+}
+
+/*
+	chartLabelCols returns the key columns of the group, else the non numeric columns before the value column. The
+	first column if all are numeric
+*/
+func chartLabelCols(csvFmt *CsvFormat, rows []DataRow, valueCol int, groupByCount int) []int {
+	var cols []int
+	if groupByCount > 0 && groupByCount <= valueCol {
+		for col := 0; col < groupByCount; col++ {
+			cols = append(cols, col)
+		}
+		return cols
+	}
+	for col := 0; col < valueCol; col++ {
+		if !isNumericCol(csvFmt, rows, col) {
+			cols = append(cols, col)
+		}
+	}
+	if len(cols) == 0 && valueCol > 0 {
+		cols = append(cols, 0)
+	}
+	return cols
+}
+
+/*
+	writeBars writes the label, the bar and the text of each bar. The longest bar is the max value, the bars of the
+	zero and the negative values are empty. The labels longer than the third of the width are truncated
+*/
+func writeBars(csvFmt *CsvFormat, bars []chartBar, labelHeader string, textHeader string) {
+	width := chartWidth(csvFmt)
+	labelWidth, textWidth, max := 0, 0, 0.0
+	for i := range bars {
+		bars[i].Label = common.TruncateWidth(bars[i].Label, MaxInt(width/3, 2))
+		labelWidth = MaxInt(labelWidth, common.DisplayWidth(bars[i].Label))
+		textWidth = MaxInt(textWidth, common.DisplayWidth(bars[i].Text))
+		max = math.Max(max, bars[i].Value)
+	}
+	if !csvFmt.NoHeaderOut {
+		labelHeader = common.TruncateWidth(labelHeader, MaxInt(width/3, 2))
+		labelWidth = MaxInt(labelWidth, common.DisplayWidth(labelHeader))
+	}
+	barWidth := MaxInt(width-labelWidth-textWidth-3, 10)
+	out := csvFmt.out()
+	if !csvFmt.NoHeaderOut {
+		fmt.Fprintln(out, strings.TrimRight(common.PadWidth(labelHeader, labelWidth, false)+"  "+textHeader, " "))
+	}
+	for _, bar := range bars {
+		eighths := 0
+		if max > 0 && bar.Value > 0 {
+			eighths = int(math.Round(bar.Value / max * float64(barWidth*8)))
+		}
+		writeBar(out, common.PadWidth(bar.Label, labelWidth, false), eighths, bar.Text)
+	}
+}
+
+func writeBar(out io.Writer, label string, eighths int, text string) {
+	var sb strings.Builder
+	sb.WriteString(label)
+	sb.WriteString("  ")
+	sb.WriteString(strings.Repeat("█", eighths/8))
+	if eighths%8 > 0 {
+		sb.WriteRune(barEighths[eighths%8-1])
+	}
+	if eighths > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString(text)
+	fmt.Fprintln(out, sb.String())
+}
+
+// chartWidth returns the $COLUMNS or the columns of the terminal, 80 if the output is not a terminal
+func chartWidth(csvFmt *CsvFormat) int {
+	if width := terminalWidth(csvFmt); width > 0 {
+		return width
+	}
+	return 80
+}
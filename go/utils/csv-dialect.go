@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"unicode/utf8"
+)
+
+// CsvDialect is the format of the csv input of split:csv
+type CsvDialect struct {
+	Comma            rune
+	Comment          rune
+	LazyQuotes       bool
+	Strict           bool
+	StripBom         bool
+	TrimLeadingSpace bool
+	SkipBad          bool
+}
+
+/*
+	split:csv..sep:;..comment:#   => the separator and the comment char. the special strs of split are supported
+	split:csv..lazy               => the quotes are allowed in the unquoted fields, and not escaped in the quoted fields
+	split:csv..strict             => the rows should have the same number of fields as the header
+	split:csv..bom                => strip the UTF-8 BOM
+	split:csv..trim               => trim the leading space of the fields
+	split:csv..skip-bad           => skip the malformed records with a warning, instead of failing
+*/
+func extractCsvDialect(arg string) *CsvDialect {
+	dialect := &CsvDialect{Comma: ','}
+	for _, part := range strings.Split(strings.TrimPrefix(arg, "split:csv"), "..") {
+		switch {
+		case part == "":
+		case strings.HasPrefix(part, "sep:"):
+			dialect.Comma = dialectRune(arg, part[4:])
+		case strings.HasPrefix(part, "comment:"):
+			dialect.Comment = dialectRune(arg, part[8:])
+		case part == "lazy":
+			dialect.LazyQuotes = true
+		case part == "strict":
+			dialect.Strict = true
+		case part == "bom":
+			dialect.StripBom = true
+		case part == "trim":
+			dialect.TrimLeadingSpace = true
+		case part == "skip-bad":
+			dialect.SkipBad = true
+		default:
+			fatalf("Invalid option '%v' in '%v'. The options are sep, comment, lazy, strict, bom, trim and skip-bad "+
+				"eg. split:csv..sep:;..strict", part, arg)
+		}
+	}
+	if dialect.Comma == dialect.Comment {
+		fatalf("The sep and the comment of '%v' should be different", arg)
+	}
+	return dialect
+}
+
+// dialectRune returns the char of the option. The special strs eg. tab, pipe are supported
+func dialectRune(arg string, value string) rune {
+	if value == "pipe" {
+		value = "|"
+	} else if value != ":" {
+		value = extractDelim(value, "")
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		fatalf("Invalid char '%v' in '%v'. A single char is expected", value, arg)
+	}
+	char, _ := utf8.DecodeRuneInString(value)
+	if char == '"' || char == '\r' || char == '\n' {
+		fatalf("Invalid char '%v' in '%v'", value, arg)
+	}
+	return char
+}
+
+/*
+	newCsvReader returns the reader of the dialect, the default csv format if the dialect is nil. The rows can have a
+	different number of fields than the header unless it is strict
+*/
+func newCsvReader(in io.Reader, dialect *CsvDialect) *csv.Reader {
+	if dialect == nil {
+		reader := csv.NewReader(bufio.NewReader(in))
+		reader.FieldsPerRecord = -1
+		return reader
+	}
+	buffered := bufio.NewReader(in)
+	if dialect.StripBom {
+		if bom, err := buffered.Peek(3); err == nil && string(bom) == "\xef\xbb\xbf" {
+			buffered.Discard(3)
+		}
+	}
+	reader := csv.NewReader(buffered)
+	reader.Comma = dialect.Comma
+	reader.Comment = dialect.Comment
+	reader.LazyQuotes = dialect.LazyQuotes
+	reader.TrimLeadingSpace = dialect.TrimLeadingSpace
+	if !dialect.Strict {
+		reader.FieldsPerRecord = -1
+	}
+	return reader
+}
+
+/*
+	readCsvRecord returns the next record, nil at the end. The malformed records are skipped with a warning in the
+	skip-bad mode, otherwise the error has the line of the record
+*/
+func readCsvRecord(reader *csv.Reader, dialect *CsvDialect) []string {
+	for {
+		words, err := reader.Read()
+		if err == nil {
+			return words
+		}
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if !errors.As(err, &parseErr) {
+			fatalf("Unable to read the input. The error is %v", err)
+		}
+		if dialect != nil && dialect.SkipBad {
+			log.Printf("Skipped the malformed csv record, %v", err)
+			continue
+		}
+		fatalf("Malformed csv record, %v. The options split:csv..lazy or split:csv..skip-bad can be used", err)
+	}
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"strings"
+)
+
+type DiffDef struct {
+	File    string
+	KeyCols *common.IntRange
+}
+
+/*
+	diff..prev.csv            => the key is the first column
+	diff..prev.csv..key[0,1]
+	diff..pods.txt..key[NAME]
+*/
+func extractDiffDef(arg string) *DiffDef {
+	parts := parseInlineCommand("diff", arg)
+	if len(parts) < 2 || parts[1] == "" {
+		fatalf("Invalid diff '%v'. The format is diff..<file>..key[<cols>]", arg)
+	}
+	def := &DiffDef{File: parts[1]}
+	for _, part := range parts[2:] {
+		if strings.Index(part, "key[") == 0 {
+			def.KeyCols = extractCsvIndexArg(part)
+		} else {
+			fatalf("Invalid diff option '%v'", part)
+		}
+	}
+	if def.KeyCols == nil {
+		def.KeyCols = common.ParseRange("0")
+	}
+	return def
+}
+
+/*
+	applyDiff compares the rows with the rows of the previous snapshot based on the key columns. The result has
+	the key columns, the change and the rest of the columns. The changed values are shown as old→new with the delta
+	for the numbers. The unchanged rows are skipped, the removed rows are added at the end
+*/
+func applyDiff(csvFmt *CsvFormat, data *DataRows) *DataRows {
+	def := csvFmt.DiffDef
+	prevLines := readTableFile(def.File, nil)
+	var prevHeaders []string
+	if !csvFmt.NoHeaderIn && len(prevLines) > 0 {
+		prevHeaders = prevLines[0]
+		prevLines = prevLines[1:]
+	}
+	resolveRange(def.KeyCols, data.Headers, "diff key")
+	count := colCount(data)
+	keyIndices := pivotIndices(def.KeyCols, data)
+	if len(keyIndices) == 0 {
+		fatalf("Invalid diff key, there are no matching columns")
+	}
+
+	//the columns of the snapshot are matched by the header name, or by the index if there are no headers
+	prevIndices := make([]int, count)
+	for i := 0; i < count; i++ {
+		prevIndices[i] = i
+		if data.Headers != nil && prevHeaders != nil {
+			prevIndices[i] = common.IndexOf(prevHeaders, outputHeader(data.Headers, i))
+		}
+	}
+	keyMap := make(map[int]bool)
+	for _, index := range keyIndices {
+		if prevIndices[index] < 0 {
+			fatalf("Invalid diff key, the column '%v' is not in %v", outputHeader(data.Headers, index), def.File)
+		}
+		keyMap[index] = true
+	}
+	var valueIndices []int
+	for i := 0; i < count; i++ {
+		if !keyMap[i] {
+			valueIndices = append(valueIndices, i)
+		}
+	}
+
+	var prevKeys []string
+	prevRows := make(map[string][]string)
+	for _, words := range prevLines {
+		key := diffKey(keyIndices, func(index int) string { return prevWord(words, prevIndices[index]) })
+		if _, exists := prevRows[key]; !exists {
+			prevKeys = append(prevKeys, key)
+		}
+		prevRows[key] = words
+	}
+
+	var headers []string
+	for _, index := range keyIndices {
+		headers = append(headers, outputHeader(data.Headers, index))
+	}
+	headers = append(headers, "change")
+	for _, index := range valueIndices {
+		headers = append(headers, outputHeader(data.Headers, index))
+	}
+
+	var rows []DataRow
+	seen := make(map[string]bool)
+	for _, row := range data.DataRows {
+		key := diffKey(keyIndices, func(index int) string { return cellString(pivotCol(row, index)) })
+		seen[key] = true
+		prev, exists := prevRows[key]
+		cols := diffKeyCols(keyIndices, func(index int) string { return cellString(pivotCol(row, index)) })
+		if !exists {
+			cols = append(cols, "added")
+			for _, index := range valueIndices {
+				cols = append(cols, pivotCol(row, index))
+			}
+			rows = append(rows, DataRow{Cols: cols})
+			continue
+		}
+		changed := false
+		var values []interface{}
+		for _, index := range valueIndices {
+			curr := cellString(pivotCol(row, index))
+			if prevIndices[index] < 0 {
+				values = append(values, curr)
+				continue
+			}
+			old := prevWord(prev, prevIndices[index])
+			if old == curr {
+				values = append(values, curr)
+				continue
+			}
+			changed = true
+			values = append(values, diffValue(old, curr))
+		}
+		if changed {
+			cols = append(cols, "changed")
+			rows = append(rows, DataRow{Cols: append(cols, values...)})
+		}
+	}
+	for _, key := range prevKeys {
+		if seen[key] {
+			continue
+		}
+		prev := prevRows[key]
+		cols := diffKeyCols(keyIndices, func(index int) string { return prevWord(prev, prevIndices[index]) })
+		cols = append(cols, "removed")
+		for _, index := range valueIndices {
+			cols = append(cols, prevWord(prev, prevIndices[index]))
+		}
+		rows = append(rows, DataRow{Cols: cols})
+	}
+	return &DataRows{DataRows: rows, Headers: headers}
+}
+
+// 808699→808760 (+61)
+func diffValue(old string, curr string) string {
+	switch delta := numericDelta(old, curr).(type) {
+	case int64:
+		return fmt.Sprintf("%v→%v (%+d)", old, curr, delta)
+	case float64:
+		return fmt.Sprintf("%v→%v (%+g)", old, curr, delta)
+	case Quantity:
+		sign := ""
+		if delta.Value >= 0 {
+			sign = "+"
+		}
+		return fmt.Sprintf("%v→%v (%v%v)", old, curr, sign, delta)
+	default:
+		return old + "→" + curr
+	}
+}
+
+func diffKey(keyIndices []int, value func(index int) string) string {
+	var keys []string
+	for _, index := range keyIndices {
+		keys = append(keys, value(index))
+	}
+	return strings.Join(keys, "\x00")
+}
+
+func diffKeyCols(keyIndices []int, value func(index int) string) []interface{} {
+	var cols []interface{}
+	for _, index := range keyIndices {
+		cols = append(cols, value(index))
+	}
+	return cols
+}
+
+func prevWord(words []string, index int) string {
+	if index >= 0 && index < len(words) {
+		return words[index]
+	}
+	return ""
+}
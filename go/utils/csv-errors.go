@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+)
+
+// CsvError is the error of an invalid flag or an invalid input. The commands exit with the message, the pipeline
+// package returns it as the error
+type CsvError struct {
+	Message string
+}
+
+func (e *CsvError) Error() string {
+	return e.Message
+}
+
+// fatalf stops the processing with the error. It is recovered by recoverError or exitOnError at the entry points
+func fatalf(format string, args ...interface{}) {
+	panic(&CsvError{Message: fmt.Sprintf(format, args...)})
+}
+
+// recoverError sets the error of fatalf, the rest of the panics are not handled
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		csvErr, ok := r.(*CsvError)
+		if !ok {
+			panic(r)
+		}
+		*err = csvErr
+	}
+}
+
+// exitOnError logs the error of fatalf and exits, same as log.Fatal
+func exitOnError() {
+	if r := recover(); r != nil {
+		csvErr, ok := r.(*CsvError)
+		if !ok {
+			panic(r)
+		}
+		log.Fatal(csvErr.Message)
+	}
+}
+
+// catchError returns the error of fatalf in the fn, used to check an arg without stopping the processing
+func catchError(fn func()) (err error) {
+	defer recoverError(&err)
+	fn()
+	return nil
+}
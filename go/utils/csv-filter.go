@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"github.com/abeytom/utilbox/common"
+	"os"
+	"strconv"
+)
+
+/*
+	filter..[2] > 100
+	'filter..[LAG] > 0 && [TOPIC] == "topic1"'
+	having..count>5
+*/
+func newFilter(exprStr string) *Filter {
+	filter := &Filter{ExprStr: exprStr}
+	expr, err := newEvaluableExpression(exprStr)
+	if err != nil {
+		fatalf("Invalid expression %v. The error is %v", exprStr, err)
+	}
+	filter.Expr = expr
+	return filter
+}
+
+func extractHavingDef(arg string, csvFmt *CsvFormat) {
+	parts := parseInlineCommand("having", arg)
+	if len(parts) < 2 {
+		fatalf("Invalid having expression '%v'", arg)
+	}
+	csvFmt.Having = newFilter(parts[1])
+	csvFmt.ColRefs = append(csvFmt.ColRefs, exprRefs(havingScope, "having", arg, 0)...)
+}
+
+// resolve maps the variables of the expression to the column indices. A variable can either be a column
+// index eg [2] or a header name eg [LAG] or count
+func (f *Filter) resolve(headers []string) {
+	if f.colIndices != nil {
+		return
+	}
+	f.wExpr = NewExprWrap(f.Expr)
+	f.colIndices = make(map[string]int)
+	for _, token := range f.Expr.Tokens() {
+		if token.Kind != govaluate.VARIABLE {
+			continue
+		}
+		name := token.Value.(string)
+		if _, exists := f.colIndices[name]; exists {
+			continue
+		}
+		index, err := strconv.Atoi(name)
+		if err != nil {
+			index = common.IndexOf(headers, name)
+			if index < 0 {
+				fatalf("Invalid expression '%v', %v", f.ExprStr, common.UnknownColumnError(name, headers))
+			}
+		}
+		f.colIndices[name] = index
+	}
+}
+
+func (f *Filter) Matches(headers []string, cols []interface{}) bool {
+	f.resolve(headers)
+	params := make(map[string]interface{})
+	for name, index := range f.colIndices {
+		if index < 0 {
+			index = len(cols) + index
+		}
+		var value interface{} = ""
+		if index >= 0 && index < len(cols) {
+			value = cols[index]
+		}
+		params[name] = f.convertValue(name, f.types[index], value)
+	}
+	evaluate, err := f.Expr.Evaluate(params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error while eval '%v' with params '%+v'. The error is '%v'\n",
+			f.ExprStr, params, err)
+		return false
+	}
+	switch evaluate.(type) {
+	case bool:
+		return evaluate.(bool)
+	}
+	return false
+}
+
+func (f *Filter) MatchesWords(headers []string, words []string) bool {
+	cols := make([]interface{}, len(words))
+	for i, word := range words {
+		cols[i] = word
+	}
+	return f.Matches(headers, cols)
+}
+
+// the string values are converted into numbers, or by the type of the column, unless they are compared with a string
+func (f *Filter) convertValue(name string, typ string, value interface{}) interface{} {
+	if col, ok := value.(common.StringCol); ok {
+		value = col.ToString()
+	}
+	if token, exists := f.wExpr.valueMap[name]; exists && token.Kind == govaluate.STRING {
+		return f.wExpr.convertValue(name, value)
+	}
+	if str, ok := value.(string); ok {
+		return exprValue(ConvertTyped(str, typ))
+	}
+	return exprValue(ConvertIfNeeded(value))
+}
+
+func applyHaving(csvFmt *CsvFormat, data *DataRows) *DataRows {
+	if csvFmt.Having == nil {
+		return data
+	}
+	if data.Headers != nil {
+		checkColRefs(csvFmt, havingScope, len(data.Headers))
+	}
+	csvFmt.Having.types = inferRowTypes(data.DataRows)
+	var rows []DataRow
+	for _, row := range data.DataRows {
+		if csvFmt.Having.Matches(data.Headers, row.Cols) {
+			rows = append(rows, row)
+		}
+	}
+	data.DataRows = rows
+	return data
+}
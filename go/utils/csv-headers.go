@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// [2] or [RESTARTS] in the calc expressions
+var calcRefRegex = regexp.MustCompile(`\[([^\]]+)\]`)
+
+// resolveRange converts the column names of the flag into indices based on the headers
+func resolveRange(r *common.IntRange, headers []string, flag string) {
+	if r == nil || !r.HasNames() {
+		return
+	}
+	if headers == nil {
+		fatalf("The column names in %v need the headers. Use the column indices or set the headers", flag)
+	}
+	if err := r.Resolve(headers); err != nil {
+		fatalf("Invalid %v, %v", flag, err)
+	}
+}
+
+// resolveInputHeaders resolves the col and tr flags; those are applied on the input columns
+func resolveInputHeaders(csvFmt *CsvFormat, headers []string) {
+	resolveRange(csvFmt.ColExt, headers, "col")
+	if len(csvFmt.ColFmtNames) == 0 {
+		return
+	}
+	if csvFmt.ColFmtMap == nil {
+		csvFmt.ColFmtMap = make(map[int][]ColumnFormat)
+	}
+	for name, formats := range csvFmt.ColFmtNames {
+		index := common.IndexOf(headers, name)
+		if index < 0 {
+			fatalf("Invalid tr, %v", common.UnknownColumnError(name, headers))
+		}
+		csvFmt.ColFmtMap[index] = append(csvFmt.ColFmtMap[index], formats...)
+	}
+	csvFmt.ColFmtNames = nil
+}
+
+/*
+	[0]+[1]    => col0+col1
+	[RESTARTS] => resolved later based on the headers
+*/
+func parseCalcExpr(rawExpr string) (string, *common.IntSet, []string) {
+	var indices common.IntSet
+	var names []string
+	expr := calcRefRegex.ReplaceAllStringFunc(rawExpr, func(ref string) string {
+		str := ref[1 : len(ref)-1]
+		index, err := strconv.Atoi(str)
+		if err != nil {
+			names = append(names, str)
+			return ref
+		}
+		indices.Add(index)
+		return fmt.Sprintf("col%d", index)
+	})
+	return expr, &indices, names
+}
+
+// resolveCalcRefs replaces the column names in the calc expressions with the column indices. A calc can refer
+// to the columns added by the preceding calc
+func resolveCalcRefs(csvFmt *CsvFormat, headers []string) {
+	var nHeaders []string
+	nHeaders = append(nHeaders, headers...)
+	for i := range csvFmt.CalcDefs {
+		def := &csvFmt.CalcDefs[i]
+		if len(def.Names) > 0 {
+			if headers == nil {
+				fatalf("The column names in calc(%v) need the headers", def.RawExpr)
+			}
+			for _, name := range def.Names {
+				index := common.IndexOf(nHeaders, name)
+				if index < 0 {
+					argError(def.Arg, calcExprPos(def.Arg), "Invalid calc(%v), %v", def.RawExpr,
+					common.UnknownColumnError(name, nHeaders))
+				}
+				def.Indices.Add(index)
+				def.ParsedExpr = strings.ReplaceAll(def.ParsedExpr, "["+name+"]", fmt.Sprintf("col%d", index))
+			}
+			expr, err := newEvaluableExpression(def.ParsedExpr)
+			if err != nil {
+				argError(def.Arg, calcExprPos(def.Arg), "Invalid calc(%v). The error is %v", def.RawExpr, err)
+			}
+			def.EvalExpr = expr
+			def.Names = nil
+		}
+		nHeaders = append(nHeaders, calcHeader(def, nHeaders))
+	}
+}
+
+// the header of the calc column is the expression with the column indices replaced by the header names
+func calcHeader(def *CalcDef, headers []string) string {
+	return calcRefRegex.ReplaceAllStringFunc(def.RawExpr, func(ref string) string {
+		str := ref[1 : len(ref)-1]
+		index, err := strconv.Atoi(str)
+		if err != nil {
+			return str
+		}
+		if index >= 0 && index < len(headers) {
+			return headers[index]
+		}
+		return ref
+	})
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"encoding/csv"
+	"github.com/abeytom/utilbox/common"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type JoinDef struct {
+	File      string
+	LeftCols  *common.IntRange
+	RightCols *common.IntRange
+	Type      string
+	Keys      []string
+}
+
+/*
+	join[nodes.csv,left=6,right=0]
+	join[nodes.csv,left=0,2,right=1,3,type=left]
+	join[pods.json,left=0,right=0,type=full,keys=metadata.name,spec.nodeName]
+*/
+func extractJoinDef(arg string) *JoinDef {
+	positional, opts := parseIndexOptions(arg)
+	if len(positional) != 1 || positional[0] == "" {
+		fatalf("Invalid join '%v'. The format is join[<file>,left=<cols>,right=<cols>,type=<type>]", arg)
+	}
+	def := &JoinDef{File: positional[0], Type: "inner"}
+	for name, values := range opts {
+		switch name {
+		case "left":
+			def.LeftCols = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "right":
+			def.RightCols = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "type":
+			def.Type = values[0]
+		case "keys":
+			def.Keys = values
+		default:
+			fatalf("Unknown join option '%v' in '%v'", name, arg)
+		}
+	}
+	switch def.Type {
+	case "inner", "left", "right", "full":
+	default:
+		fatalf("Unknown join type '%v'. The types are inner, left, right and full", def.Type)
+	}
+	if def.LeftCols == nil {
+		def.LeftCols = &common.IntRange{Indices: []int{0}}
+	}
+	if def.RightCols == nil {
+		def.RightCols = def.LeftCols
+	}
+	return def
+}
+
+/*
+	The Joiner holds the rows of the joined file in memory, keyed by the join columns. The rows from the stdin are
+	streamed through. The right side columns are appended to the left row, except the key columns
+*/
+type Joiner struct {
+	def            *JoinDef
+	Headers        []string
+	rowMap         map[string][][]string
+	keys           []string
+	matched        map[string]bool
+	valueIndices   []int
+	leftKeyIndices []int
+	leftWidth      int
+	resolved       bool
+}
+
+func NewJoiner(def *JoinDef) *Joiner {
+	lines := readTableFile(def.File, def.Keys)
+	joiner := &Joiner{
+		def:     def,
+		rowMap:  make(map[string][][]string),
+		matched: make(map[string]bool),
+	}
+	if len(lines) == 0 {
+		return joiner
+	}
+	header := lines[0]
+	keyIndices := common.GetFilterStrIndices(common.ApplyRange(header, def.RightCols))
+	if len(keyIndices) == 0 {
+		fatalf("Invalid right join columns for the file %v", def.File)
+	}
+	for i := range header {
+		if !common.BruteIntContains(keyIndices, i) {
+			joiner.valueIndices = append(joiner.valueIndices, i)
+		}
+	}
+	joiner.Headers = pickWords(header, joiner.valueIndices)
+	for _, words := range lines[1:] {
+		key := joinKey(words, keyIndices)
+		if _, exists := joiner.rowMap[key]; !exists {
+			joiner.keys = append(joiner.keys, key)
+		}
+		joiner.rowMap[key] = append(joiner.rowMap[key], words)
+	}
+	return joiner
+}
+
+// JoinHeaders appends the headers of the joined file
+func (j *Joiner) JoinHeaders(headers []string) []string {
+	j.resolve(headers)
+	var joined []string
+	joined = append(joined, headers...)
+	return append(joined, j.Headers...)
+}
+
+func (j *Joiner) resolve(words []string) {
+	if j.resolved {
+		return
+	}
+	j.leftKeyIndices = common.GetFilterStrIndices(common.ApplyRange(words, j.def.LeftCols))
+	j.leftWidth = len(words)
+	j.resolved = true
+}
+
+// Join returns the joined rows for the row. inner and right joins drop the rows without a match
+func (j *Joiner) Join(words []string) [][]string {
+	j.resolve(words)
+	key := joinKey(words, j.leftKeyIndices)
+	rows, exists := j.rowMap[key]
+	if !exists {
+		if j.def.Type == "left" || j.def.Type == "full" {
+			return [][]string{j.merge(words, nil)}
+		}
+		return nil
+	}
+	j.matched[key] = true
+	var joined [][]string
+	for _, row := range rows {
+		joined = append(joined, j.merge(words, row))
+	}
+	return joined
+}
+
+// Unmatched returns the rows of the joined file without a match for the right and full joins
+func (j *Joiner) Unmatched() [][]string {
+	if j.def.Type != "right" && j.def.Type != "full" {
+		return nil
+	}
+	leftKeyIndices := j.leftKeyIndices
+	leftWidth := j.leftWidth
+	if !j.resolved {
+		leftKeyIndices = j.def.LeftCols.Indices
+		leftWidth = len(leftKeyIndices)
+	}
+	var rows [][]string
+	for _, key := range j.keys {
+		if j.matched[key] {
+			continue
+		}
+		keys := strings.Split(key, ":==:")
+		for _, row := range j.rowMap[key] {
+			left := make([]string, leftWidth)
+			for i, index := range leftKeyIndices {
+				if index >= 0 && index < leftWidth && i < len(keys) {
+					left[index] = keys[i]
+				}
+			}
+			rows = append(rows, j.merge(left, row))
+		}
+	}
+	return rows
+}
+
+func (j *Joiner) merge(left []string, right []string) []string {
+	var joined []string
+	joined = append(joined, left...)
+	for len(joined) < j.leftWidth {
+		joined = append(joined, "")
+	}
+	return append(joined, pickWords(right, j.valueIndices)...)
+}
+
+func joinKey(words []string, indices []int) string {
+	return strings.Join(pickWords(words, indices), ":==:")
+}
+
+// the first line of the file is the header. json files are flattened based on the keys
+func readTableFile(fileName string, keys []string) [][]string {
+	file, err := os.Open(fileName)
+	if err != nil {
+		fatalf("Unable to open the file %v. The error is %v", fileName, err)
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return readTableJson(file, keys)
+	case ".tsv":
+		return readTableCsv(file, '\t')
+	case ".txt":
+		return readTableText(file)
+	default:
+		return readTableCsv(file, ',')
+	}
+}
+
+func readTableCsv(file *os.File, sep rune) [][]string {
+	reader := csv.NewReader(file)
+	reader.Comma = sep
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	lines, err := reader.ReadAll()
+	if err != nil {
+		fatalf("Unable to read the file %v. The error is %v", file.Name(), err)
+	}
+	return lines
+}
+
+// screen scraped data eg. kubectl get nodes > nodes.txt
+func readTableText(file *os.File) [][]string {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		fatalf("Unable to read the file %v. The error is %v", file.Name(), err)
+	}
+	var lines [][]string
+	for _, line := range strings.Split(string(data), "\n") {
+		words := strings.Fields(line)
+		if len(words) > 0 {
+			lines = append(lines, words)
+		}
+	}
+	return lines
+}
+
+func readTableJson(file *os.File, keys []string) [][]string {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		fatalf("Unable to read the file %v. The error is %v", file.Name(), err)
+	}
+	array := parseJsonBytes(data)
+	if array == nil {
+		fatalf("Unsupported JSON in the file %v", file.Name())
+	}
+	if len(keys) == 0 {
+		keys = jsonLeafKeys(array)
+	}
+	lines := [][]string{keys}
+	for _, row := range Flatten(array, keys) {
+		words := make([]string, len(row.Cols))
+		for i, col := range row.Cols {
+			words[i] = common.ToString(col)
+		}
+		lines = append(lines, words)
+	}
+	return lines
+}
+
+// the keys of the objects are skipped, only the keys with values are used
+func jsonLeafKeys(array []map[string]interface{}) []string {
+	entries := JsonKeys(array)
+	var keys []string
+	for i, entry := range entries {
+		isLeaf := true
+		for j, other := range entries {
+			if i != j && strings.HasPrefix(other.Key, entry.Key+".") {
+				isLeaf = false
+				break
+			}
+		}
+		if isLeaf {
+			keys = append(keys, entry.Key)
+		}
+	}
+	return keys
+}
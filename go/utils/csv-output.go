@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"gopkg.in/yaml.v2"
+	"html"
+	"io"
+	"strings"
+)
+
+// the header for the col index. the extra cols, if any, are named by the index same as the json output
+func outputHeader(headers []string, index int) string {
+	if index < len(headers) {
+		return headers[index]
+	}
+	return fmt.Sprintf("%v", index)
+}
+
+// cellValues returns the display values of a col. the objects eg. from jp are converted into json
+func cellValues(col interface{}) []string {
+	switch col.(type) {
+	case nil:
+		return nil
+	case common.StringCol:
+		return col.(common.StringCol).Values()
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}, []map[string]string:
+		bytes, err := json.Marshal(col)
+		if err != nil {
+			return []string{fmt.Sprintf("%v", col)}
+		}
+		return []string{string(bytes)}
+	default:
+		return []string{common.ToString(col)}
+	}
+}
+
+// the yaml and json lines keep the numbers as is and the grouped values as a list, same as the json output
+func structuredValue(col interface{}) interface{} {
+	switch col.(type) {
+	case common.StringCol:
+		return col.(common.StringCol).Values()
+	default:
+		return col
+	}
+}
+
+func processMarkdownOutput(rows []DataRow, headers []string, writer io.Writer) {
+	colCount := len(headers)
+	for _, row := range rows {
+		if len(row.Cols) > colCount {
+			colCount = len(row.Cols)
+		}
+	}
+	if colCount == 0 {
+		return
+	}
+	cells := make([][]string, len(rows))
+	widths := make([]int, colCount)
+	for i := 0; i < colCount; i++ {
+		widths[i] = len(escapeMarkdown(outputHeader(headers, i)))
+		if widths[i] < 3 {
+			widths[i] = 3
+		}
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, colCount)
+		for i, col := range row.Cols {
+			cells[r][i] = escapeMarkdown(strings.Join(cellValues(col), "<br>"))
+			if len(cells[r][i]) > widths[i] {
+				widths[i] = len(cells[r][i])
+			}
+		}
+	}
+	writeRow := func(vals []string) {
+		var sb strings.Builder
+		sb.WriteString("|")
+		for i, val := range vals {
+			sb.WriteString(" " + val + strings.Repeat(" ", widths[i]-len(val)) + " |")
+		}
+		fmt.Fprintln(writer, sb.String())
+	}
+	names := make([]string, colCount)
+	separators := make([]string, colCount)
+	for i := 0; i < colCount; i++ {
+		names[i] = escapeMarkdown(outputHeader(headers, i))
+		separators[i] = strings.Repeat("-", widths[i])
+	}
+	writeRow(names)
+	writeRow(separators)
+	for _, vals := range cells {
+		writeRow(vals)
+	}
+}
+
+func escapeMarkdown(str string) string {
+	str = strings.ReplaceAll(str, "\\", "\\\\")
+	str = strings.ReplaceAll(str, "|", "\\|")
+	str = strings.ReplaceAll(str, "\r\n", "<br>")
+	return strings.ReplaceAll(str, "\n", "<br>")
+}
+
+func processHtmlOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, writer io.Writer) {
+	fmt.Fprintln(writer, "<table>")
+	if !csvFmt.NoHeaderOut && len(headers) > 0 {
+		fmt.Fprintln(writer, "<thead>")
+		var sb strings.Builder
+		sb.WriteString("<tr>")
+		for _, header := range headers {
+			sb.WriteString("<th>" + html.EscapeString(header) + "</th>")
+		}
+		sb.WriteString("</tr>")
+		fmt.Fprintln(writer, sb.String())
+		fmt.Fprintln(writer, "</thead>")
+	}
+	fmt.Fprintln(writer, "<tbody>")
+	for _, row := range rows {
+		var sb strings.Builder
+		sb.WriteString("<tr>")
+		for _, col := range row.Cols {
+			vals := cellValues(col)
+			for i, val := range vals {
+				vals[i] = strings.ReplaceAll(html.EscapeString(val), "\n", "<br>")
+			}
+			sb.WriteString("<td>" + strings.Join(vals, "<br>") + "</td>")
+		}
+		sb.WriteString("</tr>")
+		fmt.Fprintln(writer, sb.String())
+	}
+	fmt.Fprintln(writer, "</tbody>")
+	fmt.Fprintln(writer, "</table>")
+}
+
+func processYamlOutput(rows []DataRow, headers []string, writer io.Writer) {
+	array := make([]yaml.MapSlice, 0)
+	for _, row := range rows {
+		item := yaml.MapSlice{}
+		for i, col := range row.Cols {
+			item = append(item, yaml.MapItem{Key: outputHeader(headers, i), Value: structuredValue(col)})
+		}
+		array = append(array, item)
+	}
+	bytes, err := yaml.Marshal(array)
+	if err != nil {
+		fmt.Fprintln(writer, err)
+		return
+	}
+	fmt.Fprint(writer, string(bytes))
+}
+
+// one json object per row, the keys are in the order of the headers
+func processJsonLinesOutput(rows []DataRow, headers []string, writer io.Writer) {
+	for _, row := range rows {
+		var sb strings.Builder
+		sb.WriteString("{")
+		for i, col := range row.Cols {
+			key, _ := json.Marshal(outputHeader(headers, i))
+			value, err := json.Marshal(structuredValue(col))
+			if err != nil {
+				value, _ = json.Marshal(fmt.Sprintf("%v", col))
+			}
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.Write(key)
+			sb.WriteString(":")
+			sb.Write(value)
+		}
+		sb.WriteString("}")
+		fmt.Fprintln(writer, sb.String())
+	}
+}
+
+func processTsvOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, writer io.Writer) {
+	if !csvFmt.NoHeaderOut && len(headers) > 0 {
+		vals := make([]string, len(headers))
+		for i, header := range headers {
+			vals[i] = escapeTsv(header)
+		}
+		fmt.Fprintln(writer, strings.Join(vals, "\t"))
+	}
+	for _, row := range rows {
+		vals := make([]string, len(row.Cols))
+		for i, col := range row.Cols {
+			vals[i] = escapeTsv(strings.Join(cellValues(col), ","))
+		}
+		fmt.Fprintln(writer, strings.Join(vals, "\t"))
+	}
+}
+
+// the tab, newline and backslash chars are escaped, the tsv fields cannot have those
+func escapeTsv(str string) string {
+	str = strings.ReplaceAll(str, "\\", "\\\\")
+	str = strings.ReplaceAll(str, "\t", "\\t")
+	str = strings.ReplaceAll(str, "\r", "\\r")
+	return strings.ReplaceAll(str, "\n", "\\n")
+}
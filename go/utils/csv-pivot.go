@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"sort"
+	"strings"
+)
+
+type PivotDef struct {
+	RowCols *common.IntRange
+	ColCol  *common.IntRange
+	ValCol  *common.IntRange
+	Agg     string
+}
+
+type UnpivotDef struct {
+	Cols *common.IntRange
+}
+
+/*
+	pivot[row=0,col=1,val=2,agg=sum]
+	pivot[row=0,2,col=STATUS]         => the count of the rows when there is no val
+*/
+func extractPivotDef(arg string) *PivotDef {
+	positional, opts := parseIndexOptions(arg)
+	if len(positional) > 0 {
+		fatalf("Invalid pivot '%v'. The format is pivot[row=<cols>,col=<col>,val=<col>,agg=<func>]", arg)
+	}
+	def := &PivotDef{}
+	for name, values := range opts {
+		switch name {
+		case "row":
+			def.RowCols = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "col":
+			def.ColCol = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "val":
+			def.ValCol = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "agg":
+			def.Agg = values[0]
+		default:
+			fatalf("Invalid pivot option '%v'", name)
+		}
+	}
+	if def.RowCols == nil || def.ColCol == nil {
+		fatalf("Invalid pivot '%v'. The row and col are required", arg)
+	}
+	if def.Agg == "" {
+		def.Agg = "sum"
+		if def.ValCol == nil {
+			def.Agg = "count"
+		}
+	}
+	if !isValidAggFunc(def.Agg) {
+		fatalf("Unknown aggregate function '%v'", def.Agg)
+	}
+	return def
+}
+
+// unpivot[2:] => the cols 2: are converted into the key, value rows
+func extractUnpivotDef(arg string) *UnpivotDef {
+	return &UnpivotDef{Cols: extractCsvIndexArg(arg)}
+}
+
+// the pivot and the unpivot are applied after the group and having
+func applyReshape(csvFmt *CsvFormat, data *DataRows) *DataRows {
+	if csvFmt.PivotDef != nil {
+		data = applyPivot(csvFmt.PivotDef, data)
+	}
+	if csvFmt.UnpivotDef != nil {
+		data = applyUnpivot(csvFmt.UnpivotDef, data)
+	}
+	return data
+}
+
+func applyPivot(def *PivotDef, data *DataRows) *DataRows {
+	resolveRange(def.RowCols, data.Headers, "pivot row")
+	resolveRange(def.ColCol, data.Headers, "pivot col")
+	resolveRange(def.ValCol, data.Headers, "pivot val")
+	rowIndices := pivotIndices(def.RowCols, data)
+	colIndex := pivotIndex(def.ColCol, data, "col")
+	valIndex := -1
+	if def.ValCol != nil {
+		valIndex = pivotIndex(def.ValCol, data, "val")
+	}
+
+	var rowKeys []string
+	rowValues := make(map[string][]interface{})
+	colValues := make(map[string]interface{})
+	cells := make(map[string]map[string]Aggregator)
+	for _, row := range data.DataRows {
+		var keyCols []interface{}
+		var keyStrs []string
+		for _, index := range rowIndices {
+			col := pivotCol(row, index)
+			keyCols = append(keyCols, col)
+			keyStrs = append(keyStrs, cellString(col))
+		}
+		rowKey := strings.Join(keyStrs, "\x00")
+		if _, exists := rowValues[rowKey]; !exists {
+			rowKeys = append(rowKeys, rowKey)
+			rowValues[rowKey] = keyCols
+			cells[rowKey] = make(map[string]Aggregator)
+		}
+		colKey := cellString(pivotCol(row, colIndex))
+		if _, exists := colValues[colKey]; !exists {
+			colValues[colKey] = ConvertIfNeeded(colKey)
+		}
+		agg, exists := cells[rowKey][colKey]
+		if !exists {
+			agg = NewAggregator(def.Agg, "")
+			cells[rowKey][colKey] = agg
+		}
+		if valIndex == -1 {
+			agg.Add("1") //each row is counted, the col value can be empty
+		} else {
+			agg.Add(cellString(pivotCol(row, valIndex)))
+		}
+	}
+
+	//the generated cols are the distinct values of the pivot col in the sorted order
+	var colKeys []string
+	for key := range colValues {
+		colKeys = append(colKeys, key)
+	}
+	sort.Slice(colKeys, func(i, j int) bool {
+		return compareValues(colValues[colKeys[i]], colValues[colKeys[j]]) < 0
+	})
+
+	var headers []string
+	if data.Headers != nil {
+		for _, index := range rowIndices {
+			headers = append(headers, outputHeader(data.Headers, index))
+		}
+	} else {
+		for i := range rowIndices {
+			headers = append(headers, fmt.Sprintf("row%d", i))
+		}
+	}
+	headers = append(headers, colKeys...)
+
+	var rows []DataRow
+	for _, rowKey := range rowKeys {
+		var cols []interface{}
+		cols = append(cols, rowValues[rowKey]...)
+		for _, colKey := range colKeys {
+			if agg, exists := cells[rowKey][colKey]; exists {
+				cols = append(cols, agg.Result())
+			} else {
+				cols = append(cols, "")
+			}
+		}
+		rows = append(rows, DataRow{Cols: cols})
+	}
+	return &DataRows{DataRows: rows, Headers: headers, Converted: true}
+}
+
+func applyUnpivot(def *UnpivotDef, data *DataRows) *DataRows {
+	resolveRange(def.Cols, data.Headers, "unpivot")
+	meltMap := make(map[int]bool)
+	for _, index := range pivotIndices(def.Cols, data) {
+		meltMap[index] = true
+	}
+	var headers []string
+	var keepIndices, meltIndices []int
+	for i := 0; i < colCount(data); i++ {
+		if meltMap[i] {
+			meltIndices = append(meltIndices, i)
+		} else {
+			keepIndices = append(keepIndices, i)
+			headers = append(headers, outputHeader(data.Headers, i))
+		}
+	}
+	headers = append(headers, "key", "value")
+
+	var rows []DataRow
+	for _, row := range data.DataRows {
+		for _, index := range meltIndices {
+			var cols []interface{}
+			for _, keep := range keepIndices {
+				cols = append(cols, pivotCol(row, keep))
+			}
+			cols = append(cols, outputHeader(data.Headers, index), pivotCol(row, index))
+			rows = append(rows, DataRow{Cols: cols})
+		}
+	}
+	return &DataRows{DataRows: rows, Headers: headers, Converted: data.Converted}
+}
+
+func colCount(data *DataRows) int {
+	count := len(data.Headers)
+	if len(data.DataRows) > 0 && len(data.DataRows[0].Cols) > count {
+		count = len(data.DataRows[0].Cols)
+	}
+	return count
+}
+
+func pivotIndices(r *common.IntRange, data *DataRows) []int {
+	var indices []int
+	for i := 0; i < colCount(data); i++ {
+		if isWithInBounds(r, i) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+func pivotIndex(r *common.IntRange, data *DataRows, name string) int {
+	indices := pivotIndices(r, data)
+	if len(indices) != 1 {
+		fatalf("Invalid pivot %v, expected a single column", name)
+	}
+	return indices[0]
+}
+
+func pivotCol(row DataRow, index int) interface{} {
+	if index < len(row.Cols) {
+		return row.Cols[index]
+	}
+	return ""
+}
+
+func cellString(col interface{}) string {
+	return strings.Join(cellValues(col), ",")
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/csv"
 	"fmt"
 	"github.com/abeytom/utilbox/common"
-	"os"
 	"strconv"
 	"strings"
 )
@@ -15,12 +14,23 @@ type LineProcessor struct {
 	RowIndex    int
 	Lines       [][]string
 	csvWriter   *CsvWriter
+	groupMap    *GroupMap
+	sorter      *ExternalSorter
+	joiner      *Joiner
+	profiler    *ColumnProfiler
+	sample      [][]string
+	inferred    bool
 }
 
 func NewLineProcessor(csvFmt *CsvFormat) *LineProcessor {
 	processor := LineProcessor{csvFmt: csvFmt}
 	if !csvFmt.HasWholeOpr {
 		processor.csvWriter = NewCsvWriter(csvFmt)
+	} else if canUseExternalSort(csvFmt) {
+		processor.sorter = NewExternalSorter(csvFmt)
+	}
+	if csvFmt.JoinDef != nil {
+		processor.joiner = NewJoiner(csvFmt.JoinDef)
 	}
 	return &processor
 }
@@ -31,35 +41,32 @@ func (p *LineProcessor) processRow(supplier func() []string) {
 		if p.RowIndex == 0 {
 			if csvFmt.NoHeaderIn {
 				//we consider this as a line
-				words := extractCsv(supplier(), csvFmt.ColExt, csvFmt.ColFmtMap)
-				if csvFmt.HasWholeOpr {
-					p.Lines = append(p.Lines, words)
-				} else {
-					if !csvFmt.NoHeaderOut {
-						p.csvWriter.WriteRaw(getFinalHeaders(csvFmt, nil))
-					}
-					p.csvWriter.Write(words)
+				p.resolveHeaders(nil)
+				raw := supplier()
+				checkColRefs(csvFmt, inputScope, len(raw))
+				words := extractCsv(raw, csvFmt.ColExt, csvFmt.ColFmtMap)
+				if p.joiner == nil {
+					checkColRefs(csvFmt, dataScope, len(words))
+				}
+				if !csvFmt.HasWholeOpr && !csvFmt.NoHeaderOut {
+					p.csvWriter.WriteRaw(getFinalHeaders(csvFmt, nil))
 				}
+				p.addLine(words)
 			} else {
 				//this is a header
-				words := extractCsv(supplier(), csvFmt.ColExt, nil)
-				if csvFmt.HasWholeOpr {
-					p.DataHeaders = words
-				} else if !csvFmt.NoHeaderOut {
-					p.csvWriter.WriteRaw(getFinalHeaders(csvFmt, words))
+				p.resolveHeaders(supplier())
+				if !csvFmt.HasWholeOpr && !csvFmt.NoHeaderOut {
+					p.csvWriter.WriteRaw(getFinalHeaders(csvFmt, p.DataHeaders))
 				}
 			}
 		} else {
-			words := extractCsv(supplier(), csvFmt.ColExt, csvFmt.ColFmtMap)
-			if csvFmt.HasWholeOpr {
-				p.Lines = append(p.Lines, words)
-			} else {
-				p.csvWriter.Write(words)
-			}
+			p.addLine(extractCsv(supplier(), csvFmt.ColExt, csvFmt.ColFmtMap))
 		}
 	} else if p.RowIndex == 0 {
 		if !csvFmt.NoHeaderIn {
-			p.DataHeaders = extractCsv(supplier(), csvFmt.ColExt, nil)
+			p.resolveHeaders(supplier())
+		} else {
+			p.resolveHeaders(nil)
 		}
 		if !csvFmt.HasWholeOpr && !csvFmt.NoHeaderOut {
 			p.csvWriter.WriteRaw(getFinalHeaders(csvFmt, p.DataHeaders))
@@ -68,10 +75,143 @@ func (p *LineProcessor) processRow(supplier func() []string) {
 	p.RowIndex = p.RowIndex + 1
 }
 
+// resolveHeaders sets the headers and resolves the column names of the flags. The input headers are nil
+// if there is no header in the input
+func (p *LineProcessor) resolveHeaders(inHeaders []string) {
+	csvFmt := p.csvFmt
+	resolveInputHeaders(csvFmt, inHeaders)
+	if inHeaders != nil {
+		checkColRefs(csvFmt, inputScope, len(inHeaders))
+		p.DataHeaders = p.joinHeaders(extractCsv(inHeaders, csvFmt.ColExt, nil))
+		checkColRefs(csvFmt, dataScope, len(p.DataHeaders))
+	}
+	headers := p.headers()
+	csvFmt.ColTypes = resolveTypeDefs(csvFmt.TypeDefs, headers)
+	if csvFmt.BucketDef != nil {
+		resolveRange(csvFmt.BucketDef.Cols, headers, "bucket")
+	}
+	if headers == nil {
+		return
+	}
+	resolveCalcRefs(csvFmt, headers)
+	if p.csvWriter != nil || p.sorter != nil {
+		//the calc is applied as the rows are written, else on the output rows
+		checkColRefs(csvFmt, calcScope, len(headers))
+	}
+	if p.sorter != nil {
+		sortHeaders := applyCalcHeaders(csvFmt, headers)
+		resolveRange(csvFmt.SortDef.SortCols, sortHeaders, "sort")
+		p.sorter.colCount = len(sortHeaders)
+	}
+}
+
+// the headers set by the user are used over the input headers
+func (p *LineProcessor) headers() []string {
+	if p.csvFmt.HeaderDef != nil && p.csvFmt.HeaderDef.Fields != nil {
+		return p.csvFmt.HeaderDef.Fields
+	}
+	return p.DataHeaders
+}
+
+func (p *LineProcessor) joinHeaders(headers []string) []string {
+	if p.joiner == nil {
+		return headers
+	}
+	return p.joiner.JoinHeaders(headers)
+}
+
+func (p *LineProcessor) addLine(words []string) {
+	if p.joiner == nil {
+		p.pushLine(words)
+		return
+	}
+	for _, joined := range p.joiner.Join(words) {
+		p.pushLine(joined)
+	}
+}
+
+// Finish adds the pending rows, if any. eg. the rows read ahead to infer the types, the unmatched rows of a right join
+func (p *LineProcessor) Finish() {
+	if !p.inferred && len(p.sample) > 0 {
+		p.inferTypes()
+	}
+	if p.joiner == nil {
+		return
+	}
+	for _, joined := range p.joiner.Unmatched() {
+		p.pushLine(joined)
+	}
+}
+
+/*
+	pushLine adds the row to the stages. The first rows are read ahead to infer the types of the columns if the values
+	are converted eg. by the filter, the calc or the sort
+*/
+func (p *LineProcessor) pushLine(words []string) {
+	csvFmt := p.csvFmt
+	if csvFmt.BucketDef != nil {
+		applyBucket(csvFmt.BucketDef, words)
+	}
+	if !p.inferred && convertsValues(csvFmt) {
+		p.sample = append(p.sample, words)
+		if len(p.sample) >= typeSampleRows {
+			p.inferTypes()
+		}
+		return
+	}
+	p.processLine(words)
+}
+
+// inferTypes infers the types of the columns from the rows read ahead, and adds those rows to the stages
+func (p *LineProcessor) inferTypes() {
+	p.inferred = true
+	p.csvFmt.InTypes = inferColTypes(p.sample)
+	if p.csvFmt.Filter != nil {
+		p.csvFmt.Filter.types = colTypes(p.csvFmt)
+	}
+	for _, words := range p.sample {
+		p.processLine(words)
+	}
+	p.sample = nil
+}
+
+func convertsValues(csvFmt *CsvFormat) bool {
+	return csvFmt.HasWholeOpr || csvFmt.Filter != nil || len(csvFmt.CalcDefs) > 0
+}
+
+func (p *LineProcessor) processLine(words []string) {
+	csvFmt := p.csvFmt
+	if csvFmt.Filter != nil && !csvFmt.Filter.MatchesWords(p.DataHeaders, words) {
+		return
+	}
+	if csvFmt.Stats {
+		//the rows are profiled as they are read
+		if p.profiler == nil {
+			p.profiler = NewColumnProfiler(p.headers(), colTypes(csvFmt))
+		}
+		p.profiler.Add(words)
+	} else if isGroupBy(csvFmt) {
+		//the rows are aggregated as they are read, only the group state is held in memory
+		if p.groupMap == nil {
+			p.groupMap = NewGroupMap(csvFmt, p.headers(), words)
+		}
+		p.groupMap.Add(words)
+	} else if p.sorter != nil {
+		p.sorter.Add(words)
+	} else if csvFmt.HasWholeOpr {
+		p.Lines = append(p.Lines, words)
+	} else {
+		p.csvWriter.Write(words)
+	}
+}
+
 func (p *LineProcessor) Close() {
 	if p.csvWriter != nil {
 		p.csvWriter.Close()
 	}
+	if p.sorter != nil {
+		p.sorter.cleanup()
+	}
 }
 
 type OutputProcessor struct {
@@ -80,19 +220,34 @@ type OutputProcessor struct {
 
 type GroupMapValue struct {
 	Values []interface{}
+	Aggs   []Aggregator
 	Count  int
 }
 
-func (mapVal *GroupMapValue) Append(values []string) {
+// the types are the types of the values set by types[], if any
+func (mapVal *GroupMapValue) Append(values []string, types []string) {
 	if mapVal.Values == nil {
 		mapVal.Values = make([]interface{}, len(values))
 	}
 	for i, value := range values {
-		mapVal.Values[i] = Merge(mapVal.Values[i], value)
+		if len(mapVal.Aggs) > i && mapVal.Aggs[i] != nil {
+			mapVal.Aggs[i].Add(value)
+		} else if mapVal.Values[i] == nil && len(types) > i && types[i] != "" {
+			mapVal.Values[i] = convertForMappingTyped(value, types[i])
+		} else {
+			mapVal.Values[i] = Merge(mapVal.Values[i], value)
+		}
 	}
 	mapVal.Count = mapVal.Count + 1
 }
 
+func (mapVal *GroupMapValue) Result(index int) interface{} {
+	if len(mapVal.Aggs) > index && mapVal.Aggs[index] != nil {
+		return mapVal.Aggs[index].Result()
+	}
+	return mapVal.Values[index]
+}
+
 func Merge(oVal interface{}, nVal string) interface{} {
 	if oVal == nil {
 		return ConvertForMapping(nVal)
@@ -115,6 +270,12 @@ func Merge(oVal interface{}, nVal string) interface{} {
 			oVal.(common.StringCol).Add(nVal)
 		}
 		return oVal
+	case Quantity:
+		qty := oVal.(Quantity)
+		if nQty, ok := parseQuantity(nVal, qty.Type); ok {
+			return qty.computed(qty.Value + nQty.Value)
+		}
+		return oVal
 	default:
 		//fixme this is an error; hanlde
 		return ConvertForMapping(nVal)
@@ -155,16 +316,25 @@ func ConvertForMapping(val string) interface{} {
 	return set
 }
 
-func Convert(val string) interface{} {
-	int64Val, err := strconv.ParseInt(val, 10, 64)
-	if err == nil {
-		return int64Val
+// the values which cannot be converted to the type, and the types which are not additive, are collected similar to
+// the strings
+func convertForMappingTyped(val string, typ string) interface{} {
+	if isAdditive(typ) {
+		nVal := ConvertTyped(val, typ)
+		if _, isStr := nVal.(string); !isStr {
+			return nVal
+		}
 	}
-	float64Val, err := strconv.ParseFloat(val, 64)
-	if err == nil {
-		return float64Val
+	set := &common.StringSet{}
+	if val != "" {
+		set.Add(val)
 	}
-	return val
+	return set
+}
+
+// Convert converts the numbers, the quantities are converted only by the type of the column. See inferColTypes
+func Convert(val string) interface{} {
+	return convertNumber(val)
 }
 
 func ConvertIfNeeded(val interface{}) interface{} {
@@ -188,6 +358,46 @@ type GroupMap struct {
 	KeyIndices   []int
 	ValueIndices []int
 	CsvFormat    *CsvFormat
+	aggFuncs     map[int]string
+	valueTypes   []string
+}
+
+// NewGroupMap computes the key and value indices based on the headers and the first row
+func NewGroupMap(csvFmt *CsvFormat, headers []string, firstRow []string) *GroupMap {
+	groupBy := csvFmt.MapRed.ColIndices
+	resolveRange(groupBy, headers, "group")
+	checkIndices(groupBy, MaxInt(len(headers), len(firstRow)), "group")
+	keyIndices := common.GetFilterStrIndices(common.ApplyRange(firstRow, groupBy))
+	var valueIndices []int
+	for i := 0; i < len(firstRow); i++ {
+		if !common.BruteIntContains(keyIndices, i) {
+			valueIndices = append(valueIndices, i)
+		}
+	}
+	var valueTypes []string
+	for _, index := range valueIndices {
+		valueTypes = append(valueTypes, colType(csvFmt, index))
+	}
+	return &GroupMap{
+		KeyIndices:   keyIndices,
+		ValueIndices: valueIndices,
+		CsvFormat:    csvFmt,
+		aggFuncs:     resolveAggDefs(csvFmt.MapRed.Aggs, headers),
+		valueTypes:   valueTypes,
+	}
+}
+
+func (groupMap *GroupMap) Add(words []string) {
+	groupMap.Put(pickWords(words, groupMap.KeyIndices), pickWords(words, groupMap.ValueIndices))
+}
+
+func (groupMap *GroupMap) ToDataRows(defHeaders []string) *DataRows {
+	return &DataRows{
+		DataRows:     groupMap.PostProcess(),
+		Headers:      applyGroupByHeaders(groupMap.CsvFormat, defHeaders, groupMap.KeyIndices),
+		GroupByCount: len(groupMap.KeyIndices),
+		Converted:    true,
+	}
 }
 
 func (groupMap *GroupMap) Put(keys []string, values []string) {
@@ -197,10 +407,24 @@ func (groupMap *GroupMap) Put(keys []string, values []string) {
 	key := strings.Join(keys, ":==:")
 	mapVal, exists := groupMap.Map[key]
 	if !exists {
-		mapVal = &GroupMapValue{}
+		mapVal = &GroupMapValue{Aggs: groupMap.newAggregators()}
 		groupMap.Map[key] = mapVal
 	}
-	mapVal.Append(values)
+	mapVal.Append(values, groupMap.valueTypes)
+}
+
+func (groupMap *GroupMap) newAggregators() []Aggregator {
+	aggDefs := groupMap.aggFuncs
+	if len(aggDefs) == 0 {
+		return nil
+	}
+	aggs := make([]Aggregator, len(groupMap.ValueIndices))
+	for i, index := range groupMap.ValueIndices {
+		if fn, exists := aggDefs[index]; exists {
+			aggs[i] = NewAggregator(fn, colType(groupMap.CsvFormat, index))
+		}
+	}
+	return aggs
 }
 
 func (groupMap *GroupMap) PostProcess() []DataRow {
@@ -239,8 +463,8 @@ func (groupMap *GroupMap) Flatten() []DataRow {
 		for i, key := range keys {
 			cols[i] = key
 		}
-		for i, val := range v.Values {
-			cols[i+keyCount] = val
+		for i := range v.Values {
+			cols[i+keyCount] = v.Result(i)
 		}
 		if showCount {
 			cols[colCount-1] = int64(v.Count)
@@ -283,6 +507,22 @@ func (s *DataRowSort) Less(i, j int) bool {
 }
 
 func (s *DataRowSort) Compare(one interface{}, two interface{}) int {
+	return compareValues(one, two)
+}
+
+/*
+	compareValues compares the numbers by the value and the rest as the strings. The quantities are compared by the
+	value only with the quantities of the same type, a number and a value which is not a number are compared as the
+	strings, so that the order is consistent for the columns which mix the types
+*/
+func compareValues(one interface{}, two interface{}) int {
+	oneQty, oneIsQty := one.(Quantity)
+	twoQty, twoIsQty := two.(Quantity)
+	if oneIsQty && twoIsQty && oneQty.Type == twoQty.Type {
+		one, two = oneQty.Value, twoQty.Value
+	} else if oneIsQty || twoIsQty || isNumber(one) != isNumber(two) {
+		return strings.Compare(cellString(one), cellString(two))
+	}
 	switch one.(type) {
 	case int:
 		twoVal := ConvInt(two, -1)
@@ -324,6 +564,17 @@ func (s *DataRowSort) Compare(one interface{}, two interface{}) int {
 	}
 }
 
+func isNumber(val interface{}) bool {
+	switch val.(type) {
+	case int, int64, float64:
+		return true
+	case string:
+		_, err := strconv.ParseFloat(val.(string), 64)
+		return err == nil
+	}
+	return false
+}
+
 func ConvInt(val interface{}, def int) int {
 	switch val.(type) {
 	case int:
@@ -386,7 +637,7 @@ type CsvWriter struct {
 func NewCsvWriter(format *CsvFormat) *CsvWriter {
 	writer := CsvWriter{CsvFormat: format}
 	if format.Merge == "csv" || (format.OutputDef != nil && format.OutputDef.Type == "csv") {
-		writer.CsvWriter = csv.NewWriter(os.Stdout)
+		writer.CsvWriter = csv.NewWriter(format.out())
 	}
 	return &writer
 }
@@ -432,7 +683,7 @@ func (w *CsvWriter) WriteRaw(words []string) {
 		if csvFormat.Wrap != "" {
 			line = csvFormat.Wrap + line + csvFormat.Wrap
 		}
-		fmt.Printf("%s\n", line)
+		fmt.Fprintf(csvFormat.out(), "%s\n", line)
 	}
 }
 
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"github.com/abeytom/utilbox/common"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type UniqDef struct {
+	Cols      *common.IntRange
+	ShowCount bool
+}
+
+type TopDef struct {
+	Count   int
+	ByCol   *common.IntRange
+	PerCols *common.IntRange
+}
+
+/*
+	uniq            => the whole row
+	uniq[0,2]
+	uniq[TOPIC]:count
+*/
+func extractUniqDef(arg string) *UniqDef {
+	def := &UniqDef{}
+	for _, part := range common.ParseSubCommandArg(arg) {
+		if strings.Index(part, "uniq[") == 0 {
+			def.Cols = extractCsvIndexArg(part)
+		} else if part == "count" {
+			def.ShowCount = true
+		}
+	}
+	return def
+}
+
+/*
+	top[3,by=4]
+	top[3,by=LAG,per=0,1]
+	'top[3 by LAG per TOPIC]'
+*/
+func extractTopDef(arg string) *TopDef {
+	arg = strings.ReplaceAll(arg, " by ", ",by=")
+	arg = strings.ReplaceAll(arg, " per ", ",per=")
+	positional, opts := parseIndexOptions(arg)
+	if len(positional) != 1 {
+		fatalf("Invalid top '%v'. The format is top[<count>,by=<col>,per=<cols>]", arg)
+	}
+	count, err := strconv.Atoi(positional[0])
+	if err != nil || count <= 0 {
+		fatalf("Invalid top count '%v'", positional[0])
+	}
+	def := &TopDef{Count: count}
+	for name, values := range opts {
+		switch name {
+		case "by":
+			def.ByCol = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		case "per":
+			def.PerCols = extractCsvIndexArg("[" + strings.Join(values, ",") + "]")
+		default:
+			fatalf("Invalid top option '%v'", name)
+		}
+	}
+	if def.ByCol == nil {
+		fatalf("Invalid top '%v', the by column is required", arg)
+	}
+	return def
+}
+
+// limit:10, tail:10
+func extractRowCount(arg string, prefix string) int {
+	count, err := strconv.Atoi(extractArg(arg, prefix))
+	if err != nil || count < 1 {
+		fatalf("Invalid %v '%v'. A positive number is expected eg. %v10", strings.TrimSuffix(prefix, ":"), arg, prefix)
+	}
+	return count
+}
+
+func hasSelect(csvFmt *CsvFormat) bool {
+	return csvFmt.UniqDef != nil || csvFmt.TopDef != nil || csvFmt.Limit > 0 || csvFmt.Tail > 0
+}
+
+// the uniq, top, limit and tail are applied after the sort and win
+func applySelect(csvFmt *CsvFormat, rows []DataRow, headers []string) ([]DataRow, []string) {
+	if csvFmt.UniqDef != nil {
+		rows, headers = applyUniq(csvFmt.UniqDef, rows, headers)
+	}
+	if csvFmt.TopDef != nil {
+		rows = applyTop(csvFmt, rows, headers)
+	}
+	if csvFmt.Limit > 0 && len(rows) > csvFmt.Limit {
+		rows = rows[:csvFmt.Limit]
+	}
+	if csvFmt.Tail > 0 && len(rows) > csvFmt.Tail {
+		rows = rows[len(rows)-csvFmt.Tail:]
+	}
+	return rows, headers
+}
+
+// the first row of the duplicates is retained, the count is added as the last column
+func applyUniq(def *UniqDef, rows []DataRow, headers []string) ([]DataRow, []string) {
+	if len(rows) == 0 {
+		return rows, headers
+	}
+	var indices []int
+	if def.Cols != nil {
+		resolveRange(def.Cols, headers, "uniq")
+		indices = rangeIndices(def.Cols, rows[0])
+	}
+	var keys []string
+	uniqRows := make(map[string]DataRow)
+	counts := make(map[string]int64)
+	for _, row := range rows {
+		key := rowKey(row, indices)
+		if _, exists := uniqRows[key]; !exists {
+			keys = append(keys, key)
+			uniqRows[key] = row
+		}
+		counts[key]++
+	}
+	var nRows []DataRow
+	for _, key := range keys {
+		row := uniqRows[key]
+		if def.ShowCount {
+			row.Cols = append(append([]interface{}{}, row.Cols...), counts[key])
+		}
+		nRows = append(nRows, row)
+	}
+	if def.ShowCount && headers != nil {
+		headers = append(append([]string{}, headers...), "count")
+	}
+	return nRows, headers
+}
+
+// the top rows of a group are in the descending order of the by column. The groups are in the order they are seen
+func applyTop(csvFmt *CsvFormat, rows []DataRow, headers []string) []DataRow {
+	def := csvFmt.TopDef
+	if len(rows) == 0 {
+		return rows
+	}
+	resolveRange(def.ByCol, headers, "top by")
+	byIndices := rangeIndices(def.ByCol, rows[0])
+	if len(byIndices) != 1 {
+		fatalf("Invalid top by, expected a single column")
+	}
+	byIndex := byIndices[0]
+	var perIndices []int
+	if def.PerCols != nil {
+		resolveRange(def.PerCols, headers, "top per")
+		perIndices = rangeIndices(def.PerCols, rows[0])
+	}
+	var keys []string
+	groups := make(map[string][]DataRow)
+	for _, row := range rows {
+		key := ""
+		if perIndices != nil {
+			key = rowKey(row, perIndices)
+		}
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+	var nRows []DataRow
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool {
+			return compareValues(convertCol(csvFmt, byIndex, pivotCol(group[i], byIndex)),
+				convertCol(csvFmt, byIndex, pivotCol(group[j], byIndex))) > 0
+		})
+		if len(group) > def.Count {
+			group = group[:def.Count]
+		}
+		nRows = append(nRows, group...)
+	}
+	return nRows
+}
+
+// the key of the whole row if there are no indices
+func rowKey(row DataRow, indices []int) string {
+	var keys []string
+	if indices == nil {
+		for _, col := range row.Cols {
+			keys = append(keys, cellString(col))
+		}
+	} else {
+		for _, index := range indices {
+			keys = append(keys, cellString(pivotCol(row, index)))
+		}
+	}
+	return strings.Join(keys, "\x00")
+}
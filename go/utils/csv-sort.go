@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"container/heap"
+	"encoding/csv"
+	"github.com/abeytom/utilbox/common"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSortMemLimit = 256 * 1024 * 1024
+
+/*
+	The ExternalSorter sorts the rows in memory till the memory threshold is reached. Once the threshold is passed
+	the sorted rows are written into a temp file as a sorted run. The runs are merged while writing the output
+*/
+type ExternalSorter struct {
+	csvFmt   *CsvFormat
+	memLimit int64
+	indices  []int
+	buffer   []sortRow
+	bufBytes int64
+	runs     []string
+	resolved bool
+	colCount int
+}
+
+type sortRow struct {
+	words []string
+	keys  []interface{}
+}
+
+func NewExternalSorter(csvFmt *CsvFormat) *ExternalSorter {
+	memLimit := csvFmt.MemLimit
+	if memLimit <= 0 {
+		memLimit = defaultSortMemLimit
+	}
+	return &ExternalSorter{csvFmt: csvFmt, memLimit: memLimit}
+}
+
+// only the plain csv output can be streamed from the sorted runs. rest of the outputs need all the rows
+func canUseExternalSort(csvFmt *CsvFormat) bool {
+	if csvFmt.SortDef == nil || csvFmt.MapRed != nil || csvFmt.IsLMerge ||
+		csvFmt.PivotDef != nil || csvFmt.UnpivotDef != nil || csvFmt.WindowDef != nil || csvFmt.DiffDef != nil ||
+		hasSelect(csvFmt) || csvFmt.Stats {
+		return false
+	}
+	return csvFmt.OutputDef == nil || csvFmt.OutputDef.Type == "csv"
+}
+
+func (s *ExternalSorter) Add(words []string) {
+	if len(s.csvFmt.CalcDefs) > 0 {
+		cols := make([]interface{}, len(words))
+		for i, v := range words {
+			cols[i] = v
+		}
+		row := applyCalc(s.csvFmt, &DataRow{Cols: cols})
+		words = make([]string, len(row.Cols))
+		for i, col := range row.Cols {
+			words[i] = common.ToString(col)
+		}
+	}
+	if !s.resolved {
+		resolveRange(s.csvFmt.SortDef.SortCols, nil, "sort")
+		checkIndices(s.csvFmt.SortDef.SortCols, MaxInt(len(words), s.colCount), "sort")
+		s.indices = common.GetFilterStrIndices(common.ApplyRange(words, s.csvFmt.SortDef.SortCols))
+		s.resolved = true
+	}
+	s.buffer = append(s.buffer, s.newSortRow(words))
+	s.bufBytes += estimateRowBytes(words)
+	if s.bufBytes >= s.memLimit {
+		s.spill()
+	}
+}
+
+func (s *ExternalSorter) newSortRow(words []string) sortRow {
+	keys := make([]interface{}, len(s.indices))
+	for i, index := range s.indices {
+		if index >= 0 && index < len(words) {
+			keys[i] = ConvertTyped(words[index], colType(s.csvFmt, index))
+		} else {
+			keys[i] = ""
+		}
+	}
+	return sortRow{words: words, keys: keys}
+}
+
+func (s *ExternalSorter) compare(one *sortRow, two *sortRow) int {
+	for i := range one.keys {
+		compare := compareValues(one.keys[i], two.keys[i])
+		if compare == 0 {
+			continue
+		}
+		if s.csvFmt.SortDef.Desc {
+			return -compare
+		}
+		return compare
+	}
+	return 0
+}
+
+func (s *ExternalSorter) sortBuffer() {
+	sort.SliceStable(s.buffer, func(i, j int) bool {
+		return s.compare(&s.buffer[i], &s.buffer[j]) < 0
+	})
+}
+
+func (s *ExternalSorter) spill() {
+	s.sortBuffer()
+	file, err := os.CreateTemp("", "utilbox-sort-*.csv")
+	if err != nil {
+		fatalf("Unable to create the temp file for sorting. The error is %v", err)
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	for _, row := range s.buffer {
+		if err := writer.Write(row.words); err != nil {
+			fatalf("Unable to write the sorted run %v. The error is %v", file.Name(), err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fatalf("Unable to write the sorted run %v. The error is %v", file.Name(), err)
+	}
+	s.runs = append(s.runs, file.Name())
+	s.buffer = nil
+	s.bufBytes = 0
+}
+
+// WriteSorted writes the headers followed by the sorted rows
+func (s *ExternalSorter) WriteSorted(headers []string) {
+	defer s.cleanup()
+	writer := NewCsvWriter(s.csvFmt)
+	defer writer.Close()
+	if !s.csvFmt.NoHeaderOut && headers != nil {
+		writer.WriteRaw(applyCalcHeaders(s.csvFmt, headers))
+	}
+	s.sortBuffer()
+	if len(s.runs) == 0 {
+		for _, row := range s.buffer {
+			writer.WriteRaw(row.words)
+		}
+		return
+	}
+	s.merge(writer)
+}
+
+func (s *ExternalSorter) merge(writer *CsvWriter) {
+	mergeHeap := &runHeap{sorter: s}
+	for _, run := range s.runs {
+		file, err := os.Open(run)
+		if err != nil {
+			fatalf("Unable to read the sorted run %v. The error is %v", run, err)
+		}
+		defer file.Close()
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		cursor := &runCursor{reader: reader, runIndex: len(mergeHeap.cursors)}
+		if cursor.next(s) {
+			mergeHeap.cursors = append(mergeHeap.cursors, cursor)
+		}
+	}
+	//the in-memory rows are the last run
+	memCursor := &runCursor{rows: s.buffer, runIndex: len(s.runs)}
+	if memCursor.next(s) {
+		mergeHeap.cursors = append(mergeHeap.cursors, memCursor)
+	}
+	heap.Init(mergeHeap)
+	for mergeHeap.Len() > 0 {
+		cursor := mergeHeap.cursors[0]
+		writer.WriteRaw(cursor.row.words)
+		if cursor.next(s) {
+			heap.Fix(mergeHeap, 0)
+		} else {
+			heap.Pop(mergeHeap)
+		}
+	}
+}
+
+func (s *ExternalSorter) cleanup() {
+	for _, run := range s.runs {
+		os.Remove(run)
+	}
+	s.runs = nil
+}
+
+type runCursor struct {
+	reader   *csv.Reader
+	rows     []sortRow
+	row      sortRow
+	runIndex int
+}
+
+func (c *runCursor) next(s *ExternalSorter) bool {
+	if c.reader == nil {
+		if len(c.rows) == 0 {
+			return false
+		}
+		c.row = c.rows[0]
+		c.rows = c.rows[1:]
+		return true
+	}
+	words, err := c.reader.Read()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		fatalf("Unable to read the sorted run. The error is %v", err)
+	}
+	c.row = s.newSortRow(words)
+	return true
+}
+
+type runHeap struct {
+	cursors []*runCursor
+	sorter  *ExternalSorter
+}
+
+func (h *runHeap) Len() int {
+	return len(h.cursors)
+}
+
+// the rows with the same sort keys are picked in the order of the runs to keep the sort stable
+func (h *runHeap) Less(i, j int) bool {
+	compare := h.sorter.compare(&h.cursors[i].row, &h.cursors[j].row)
+	if compare == 0 {
+		return h.cursors[i].runIndex < h.cursors[j].runIndex
+	}
+	return compare < 0
+}
+
+func (h *runHeap) Swap(i, j int) {
+	h.cursors[i], h.cursors[j] = h.cursors[j], h.cursors[i]
+}
+
+func (h *runHeap) Push(x interface{}) {
+	h.cursors = append(h.cursors, x.(*runCursor))
+}
+
+func (h *runHeap) Pop() interface{} {
+	old := h.cursors
+	last := old[len(old)-1]
+	h.cursors = old[:len(old)-1]
+	return last
+}
+
+// rough estimate of the memory held by a row; string data + slice and string headers
+func estimateRowBytes(words []string) int64 {
+	size := int64(64)
+	for _, word := range words {
+		size += int64(len(word)) + 32
+	}
+	return size
+}
+
+/*
+	mem:512m
+	mem:2g
+	mem:100000 => bytes
+*/
+func parseMemSize(arg string) int64 {
+	str := strings.ToLower(strings.TrimSpace(extractArg(arg, "mem:")))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "b"), "i")
+	multiplier := int64(1)
+	if len(str) > 0 {
+		switch str[len(str)-1] {
+		case 'k':
+			multiplier = 1024
+		case 'm':
+			multiplier = 1024 * 1024
+		case 'g':
+			multiplier = 1024 * 1024 * 1024
+		}
+		if multiplier > 1 {
+			str = str[:len(str)-1]
+		}
+	}
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size <= 0 {
+		fatalf("Invalid memory size %v", arg)
+	}
+	return size * multiplier
+}
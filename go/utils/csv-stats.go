@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	statsDistinctLimit = 10000
+	statsTopCount      = 5
+	statsHistogramBins = 8
+	statsTopWidth      = 24
+)
+
+var statsHeaders = []string{"column", "type", "count", "empty", "distinct", "min", "max", "mean", "stddev", "top",
+	"histogram"}
+
+var histogramBars = []rune("▁▂▃▄▅▆▇█")
+
+/*
+	ColumnStats is the profile of a column. The numeric stats are computed as the values are read, the counts of the
+	distinct values are held upto the statsDistinctLimit for the distinct, the top values and the histogram. Those are
+	partial if the column has more distinct values, the distinct is shown as >10000
+*/
+type ColumnStats struct {
+	Count   int
+	Empty   int
+	Values  map[string]int
+	Capped  bool
+	Types   map[string]int
+	numbers int
+	min     float64
+	max     float64
+	mean    float64
+	m2      float64
+	qty     Quantity
+}
+
+// ColumnProfiler profiles the columns of the rows, a ColumnStats for each column
+type ColumnProfiler struct {
+	headers []string
+	types   map[int]string
+	stats   []*ColumnStats
+}
+
+/*
+	checkStats fails if the stats is set with the flags of the rows after the input. The stats profile the input rows
+	after the col, row, filter and bucket, the rest of the flags would be ignored
+*/
+func checkStats(csvFmt *CsvFormat) {
+	if !csvFmt.Stats {
+		return
+	}
+	var flags []string
+	add := func(isSet bool, flag string) {
+		if isSet {
+			flags = append(flags, flag)
+		}
+	}
+	add(csvFmt.MapRed != nil, "group")
+	add(csvFmt.Having != nil, "having")
+	add(len(csvFmt.CalcDefs) > 0, "calc")
+	add(csvFmt.SortDef != nil, "sort")
+	add(csvFmt.UniqDef != nil, "uniq")
+	add(csvFmt.TopDef != nil, "top")
+	add(csvFmt.Limit > 0, "limit")
+	add(csvFmt.Tail > 0, "tail")
+	add(csvFmt.PivotDef != nil, "pivot")
+	add(csvFmt.UnpivotDef != nil, "unpivot")
+	add(csvFmt.WindowDef != nil, "win")
+	add(csvFmt.DiffDef != nil, "diff")
+	if len(flags) > 0 {
+		fatalf("The stats cannot be used with %v. The stats profile the rows after the col, row, filter and bucket",
+			strings.Join(flags, ", "))
+	}
+}
+
+func NewColumnProfiler(headers []string, types map[int]string) *ColumnProfiler {
+	profiler := &ColumnProfiler{headers: headers, types: types}
+	profiler.grow(len(headers))
+	return profiler
+}
+
+func (p *ColumnProfiler) grow(count int) {
+	for len(p.stats) < count {
+		p.stats = append(p.stats, &ColumnStats{Values: make(map[string]int), Types: make(map[string]int)})
+	}
+}
+
+// Add profiles the words of a csv row. The values are converted by the types[], if any. The missing words of a short
+// row are empty
+func (p *ColumnProfiler) Add(words []string) {
+	p.grow(len(words))
+	for i, stats := range p.stats {
+		if i < len(words) {
+			stats.addString(words[i], p.types[i])
+		} else {
+			stats.addString("", "")
+		}
+	}
+}
+
+// AddCols profiles the cols of a json or a yaml row. The values of a list are profiled as separate values
+func (p *ColumnProfiler) AddCols(cols []interface{}) {
+	p.grow(len(cols))
+	for i, col := range cols {
+		stats := p.stats[i]
+		switch col.(type) {
+		case nil:
+			stats.addString("", "")
+		case float64:
+			//the json numbers are float64, the whole numbers are ints
+			value := col.(float64)
+			if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+				stats.addValue(strconv.FormatFloat(value, 'f', -1, 64), int64(value))
+			} else {
+				stats.addValue(strconv.FormatFloat(value, 'f', -1, 64), value)
+			}
+		case int:
+			stats.addValue(strconv.Itoa(col.(int)), int64(col.(int)))
+		case bool:
+			stats.addString(strconv.FormatBool(col.(bool)), p.types[i])
+		default:
+			values := cellValues(col)
+			if len(values) == 0 {
+				stats.addString("", "")
+			}
+			for _, value := range values {
+				stats.addString(value, p.types[i])
+			}
+		}
+	}
+}
+
+func (s *ColumnStats) addString(str string, typ string) {
+	if str == "" || strings.EqualFold(str, "null") {
+		s.Count++
+		s.Empty++
+		return
+	}
+	s.addValue(str, ConvertTyped(str, typ))
+}
+
+func (s *ColumnStats) addValue(key string, value interface{}) {
+	s.Count++
+	if _, exists := s.Values[key]; exists || len(s.Values) < statsDistinctLimit {
+		s.Values[key]++
+	} else {
+		s.Capped = true
+	}
+	switch value.(type) {
+	case int64:
+		s.addNumber("int", float64(value.(int64)))
+	case float64:
+		s.addNumber("float", value.(float64))
+	case Quantity:
+		qty := value.(Quantity)
+		s.qty = qty.computed(0)
+		s.addNumber(qty.Type, qty.Value)
+	default:
+		if key == "true" || key == "false" {
+			s.Types["bool"]++
+		} else {
+			s.Types["string"]++
+		}
+	}
+}
+
+// addNumber updates the min, the max and the running mean and variance by the Welford's method
+func (s *ColumnStats) addNumber(typ string, value float64) {
+	s.Types[typ]++
+	s.numbers++
+	if s.numbers == 1 || value < s.min {
+		s.min = value
+	}
+	if s.numbers == 1 || value > s.max {
+		s.max = value
+	}
+	delta := value - s.mean
+	s.mean += delta / float64(s.numbers)
+	s.m2 += delta * (value - s.mean)
+}
+
+// Type is the type of the non empty values. The ints and the floats are float, the rest of the mixes are string
+func (s *ColumnStats) Type() string {
+	if len(s.Types) == 0 {
+		return "empty"
+	}
+	if len(s.Types) == 1 {
+		for typ := range s.Types {
+			return typ
+		}
+	}
+	if len(s.Types) == 2 && s.Types["int"] > 0 && s.Types["float"] > 0 {
+		return "float"
+	}
+	if s.Types["string"] > 0 || s.Types["bool"] > 0 {
+		return "string"
+	}
+	return "mixed"
+}
+
+func (s *ColumnStats) isNumeric() bool {
+	switch s.Type() {
+	case "empty", "string", "bool", "mixed":
+		return false
+	}
+	return true
+}
+
+// bound formats the min or the max same as the values of the column, the int or the quantity
+func (s *ColumnStats) bound(value float64) interface{} {
+	switch s.Type() {
+	case "int":
+		return int64(value)
+	case "float":
+		return value
+	}
+	return s.qty.computed(value).String()
+}
+
+// spread formats the mean or the stddev. The stddev of the times is a duration
+func (s *ColumnStats) spread(value float64, isStddev bool) interface{} {
+	switch s.Type() {
+	case "int", "float":
+		return value
+	case TypeTime:
+		if isStddev {
+			return Quantity{Value: value, Type: TypeDuration}.String()
+		}
+	}
+	return s.qty.computed(value).String()
+}
+
+func (s *ColumnStats) distinct() interface{} {
+	if s.Capped {
+		return fmt.Sprintf(">%v", len(s.Values))
+	}
+	return len(s.Values)
+}
+
+// top returns the most frequent values with the counts eg. Running (12), Pending (2)
+func (s *ColumnStats) top() string {
+	values := make([]string, 0, len(s.Values))
+	for value := range s.Values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if s.Values[values[i]] != s.Values[values[j]] {
+			return s.Values[values[i]] > s.Values[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	var top []string
+	for i := 0; i < len(values) && i < statsTopCount; i++ {
+		top = append(top, fmt.Sprintf("%v (%v)", common.TruncateWidth(values[i], statsTopWidth), s.Values[values[i]]))
+	}
+	return strings.Join(top, ", ")
+}
+
+// histogram returns the bars of the counts of the values in the equal width bins between the min and the max
+func (s *ColumnStats) histogram() string {
+	if !s.isNumeric() {
+		return ""
+	}
+	if s.min == s.max {
+		return string(histogramBars[len(histogramBars)-1])
+	}
+	counts := make([]int, statsHistogramBins)
+	width := (s.max - s.min) / statsHistogramBins
+	for key, count := range s.Values {
+		value, ok := numericValue(ConvertTyped(key, s.qtyType()))
+		if !ok {
+			continue
+		}
+		counts[histogramBin(value, s.min, width, statsHistogramBins)] += count
+	}
+	maxCount := 0
+	for _, count := range counts {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	var bars strings.Builder
+	for _, count := range counts {
+		if count == 0 || maxCount == 0 {
+			bars.WriteRune(' ')
+			continue
+		}
+		level := int(math.Ceil(float64(count)*float64(len(histogramBars))/float64(maxCount))) - 1
+		bars.WriteRune(histogramBars[level])
+	}
+	return bars.String()
+}
+
+// qtyType is the type to convert the values of the column, the quantities are not inferred for the string values
+func (s *ColumnStats) qtyType() string {
+	switch s.Type() {
+	case "int", "float":
+		return TypeNumber
+	}
+	return s.Type()
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch value.(type) {
+	case int64:
+		return float64(value.(int64)), true
+	case float64:
+		return value.(float64), true
+	case Quantity:
+		return value.(Quantity).Value, true
+	}
+	return 0, false
+}
+
+// ToDataRows returns a row of the stats for each column
+func (p *ColumnProfiler) ToDataRows() *DataRows {
+	var rows []DataRow
+	for i, stats := range p.stats {
+		if stats.Capped {
+			log.Printf("The column %v has more than %v distinct values, the top and the histogram are based on "+
+				"the first %v", outputHeader(p.headers, i), statsDistinctLimit, statsDistinctLimit)
+		}
+		row := []interface{}{outputHeader(p.headers, i), stats.Type(), stats.Count, stats.Empty, stats.distinct(),
+			"", "", "", "", stats.top(), stats.histogram()}
+		if stats.isNumeric() {
+			row[5] = stats.bound(stats.min)
+			row[6] = stats.bound(stats.max)
+			row[7] = stats.spread(stats.mean, false)
+			if stats.numbers > 1 {
+				row[8] = stats.spread(math.Sqrt(stats.m2/float64(stats.numbers-1)), true)
+			}
+		}
+		rows = append(rows, DataRow{Cols: row})
+	}
+	return &DataRows{DataRows: rows, Headers: statsHeaders, Converted: true}
+}
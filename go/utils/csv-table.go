@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"gopkg.in/yaml.v2"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// the min width of the text columns while fitting the table to the terminal
+const minTableColWidth = 8
+
+// tableStyle is the border of the table. The corners are the left, the middle and the right
+type tableStyle struct {
+	vertical   string
+	horizontal string
+	top        [3]string
+	middle     [3]string
+	bottom     [3]string
+}
+
+var tableStyles = map[string]*tableStyle{
+	"plain":   nil,
+	"ascii":   {"|", "-", [3]string{"+", "+", "+"}, [3]string{"+", "+", "+"}, [3]string{"+", "+", "+"}},
+	"box":     {"│", "─", [3]string{"┌", "┬", "┐"}, [3]string{"├", "┼", "┤"}, [3]string{"└", "┴", "┘"}},
+	"rounded": {"│", "─", [3]string{"╭", "┬", "╮"}, [3]string{"├", "┼", "┤"}, [3]string{"╰", "┴", "╯"}},
+}
+
+var tableStyleNames = []string{"plain", "ascii", "box", "rounded"}
+
+// textTable is the cells of the table output, a cell has a line for each value of the grouped or the yaml values
+type textTable struct {
+	headers []string
+	rows    [][][]string
+	widths  []int
+	numeric []bool
+}
+
+/*
+	ProcessTableOutput writes the rows as an aligned table. The widths are the display widths, so the CJK chars and
+	the emojis are aligned, the numbers are aligned to the right. The table is fitted to the $COLUMNS or the width of
+	the terminal by truncating the wide text columns, or by wrapping those with out..table..wrap
+*/
+func ProcessTableOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, writer io.Writer) {
+	table := newTextTable(rows, headers)
+	def := csvFmt.OutputDef
+	if def == nil {
+		def = &OutputDef{}
+	}
+	style := tableStyles[def.Style]
+	table.fit(def.ColWidth, terminalWidth(csvFmt), style)
+	if def.Wrap {
+		table.wrap()
+	}
+	table.write(writer, style, !csvFmt.NoHeaderOut)
+}
+
+func newTextTable(rows []DataRow, headers []string) *textTable {
+	table := &textTable{headers: headers, rows: make([][][]string, len(rows))}
+	colCount := len(headers)
+	for r, row := range rows {
+		cells := make([][]string, len(row.Cols))
+		for i, col := range row.Cols {
+			cells[i] = tableCellLines(col)
+		}
+		table.rows[r] = cells
+		colCount = MaxInt(colCount, len(cells))
+	}
+	table.widths = make([]int, colCount)
+	table.numeric = make([]bool, colCount)
+	for i, header := range headers {
+		table.widths[i] = common.DisplayWidth(header)
+	}
+	for i := 0; i < colCount; i++ {
+		numbers := 0
+		table.numeric[i] = true
+		for _, cells := range table.rows {
+			if i >= len(cells) {
+				continue
+			}
+			for _, line := range cells[i] {
+				table.widths[i] = MaxInt(table.widths[i], common.DisplayWidth(line))
+				if line == "" {
+					continue
+				}
+				if _, isStr := convertNumber(line).(string); isStr {
+					table.numeric[i] = false
+				}
+				numbers++
+			}
+		}
+		table.numeric[i] = table.numeric[i] && numbers > 0
+	}
+	return table
+}
+
+// tableCellLines returns the lines of a col. The objects eg. from jp are shown as yaml
+func tableCellLines(col interface{}) []string {
+	switch col.(type) {
+	case nil:
+		return []string{""}
+	case common.StringCol:
+		vals := col.(common.StringCol).Values()
+		if len(vals) == 0 {
+			return []string{""}
+		}
+		return vals
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}, []map[string]string:
+		bytes, err := yaml.Marshal(col)
+		if err != nil {
+			return []string{fmt.Sprintf("%v", col)}
+		}
+		return common.NewOrderedStringSet(strings.Split(string(bytes), "\n")).Values()
+	case string:
+		return strings.Split(strings.TrimRight(col.(string), "\r\n"), "\n")
+	default:
+		return []string{common.ToString(col)}
+	}
+}
+
+/*
+	fit limits the widths of the text columns to the colWidth, and shrinks the widest text columns until the table
+	fits in the termWidth. The numbers are not shrunk. The termWidth is 0 if the output is not a terminal
+*/
+func (t *textTable) fit(colWidth int, termWidth int, style *tableStyle) {
+	for i := range t.widths {
+		if colWidth > 0 && !t.numeric[i] && t.widths[i] > colWidth {
+			t.widths[i] = colWidth
+		}
+	}
+	if termWidth <= 0 || len(t.widths) == 0 {
+		return
+	}
+	// the plain columns are separated by 4 spaces, the bordered columns by the 3 chars of " │ "
+	available := termWidth - 4*len(t.widths)
+	if style != nil {
+		available = termWidth - 3*len(t.widths) - 1
+	}
+	total := 0
+	for _, width := range t.widths {
+		total += width
+	}
+	for total > available {
+		widest := -1
+		for i, width := range t.widths {
+			if !t.numeric[i] && width > minTableColWidth && (widest < 0 || width > t.widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			return
+		}
+		t.widths[widest]--
+		total--
+	}
+}
+
+// wrap splits the lines wider than the column into the multiple lines, same as the grouped values
+func (t *textTable) wrap() {
+	for _, cells := range t.rows {
+		for i, lines := range cells {
+			var wrapped []string
+			for _, line := range lines {
+				wrapped = append(wrapped, common.WrapWidth(line, t.widths[i])...)
+			}
+			cells[i] = wrapped
+		}
+	}
+}
+
+func (t *textTable) write(writer io.Writer, style *tableStyle, showHeaders bool) {
+	if style == nil {
+		if showHeaders {
+			t.writePlainLine(writer, t.headers, len(t.headers))
+		}
+		for _, cells := range t.rows {
+			for l := 0; l < cellLineCount(cells); l++ {
+				t.writePlainLine(writer, cellLine(cells, l), len(cells))
+			}
+		}
+		return
+	}
+	t.writeBorder(writer, style, style.top)
+	if showHeaders && len(t.headers) > 0 {
+		t.writeStyledLine(writer, style, t.headers)
+		t.writeBorder(writer, style, style.middle)
+	}
+	for _, cells := range t.rows {
+		for l := 0; l < cellLineCount(cells); l++ {
+			t.writeStyledLine(writer, style, cellLine(cells, l))
+		}
+	}
+	t.writeBorder(writer, style, style.bottom)
+}
+
+// writePlainLine writes the first count cells, each is padded to the width of the column and 4 spaces
+func (t *textTable) writePlainLine(writer io.Writer, vals []string, count int) {
+	var sb strings.Builder
+	for i := 0; i < count; i++ {
+		sb.WriteString(t.cell(vals, i))
+		sb.WriteString("    ")
+	}
+	fmt.Fprintln(writer, sb.String())
+}
+
+func (t *textTable) writeStyledLine(writer io.Writer, style *tableStyle, vals []string) {
+	var sb strings.Builder
+	sb.WriteString(style.vertical)
+	for i := range t.widths {
+		sb.WriteString(" ")
+		sb.WriteString(t.cell(vals, i))
+		sb.WriteString(" ")
+		sb.WriteString(style.vertical)
+	}
+	fmt.Fprintln(writer, sb.String())
+}
+
+func (t *textTable) writeBorder(writer io.Writer, style *tableStyle, corners [3]string) {
+	var sb strings.Builder
+	sb.WriteString(corners[0])
+	for i, width := range t.widths {
+		if i > 0 {
+			sb.WriteString(corners[1])
+		}
+		sb.WriteString(strings.Repeat(style.horizontal, width+2))
+	}
+	sb.WriteString(corners[2])
+	fmt.Fprintln(writer, sb.String())
+}
+
+// cell returns the value of the column truncated and padded to the width of the column
+func (t *textTable) cell(vals []string, index int) string {
+	val := ""
+	if index < len(vals) {
+		val = common.TruncateWidth(vals[index], t.widths[index])
+	}
+	return common.PadWidth(val, t.widths[index], t.numeric[index])
+}
+
+func cellLineCount(cells [][]string) int {
+	count := 1
+	for _, lines := range cells {
+		count = MaxInt(count, len(lines))
+	}
+	return count
+}
+
+// cellLine returns the line of each cell, empty if the cell has fewer lines
+func cellLine(cells [][]string, line int) []string {
+	vals := make([]string, len(cells))
+	for i, lines := range cells {
+		if line < len(lines) {
+			vals[i] = lines[line]
+		}
+	}
+	return vals
+}
+
+// terminalWidth returns the $COLUMNS or the columns of the terminal, 0 if the output is not a terminal
+func terminalWidth(csvFmt *CsvFormat) int {
+	if csvFmt.Writer != nil {
+		return 0
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	if term, err := openTerminal(csvFmt); err == nil {
+		defer term.Close()
+		_, cols := term.size()
+		return cols
+	}
+	return 0
+}
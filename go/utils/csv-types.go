@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeDuration = "duration"
+	TypeBytes    = "bytes"
+	TypeCpu      = "cpu"
+	TypePercent  = "percent"
+	TypeTime     = "time"
+	TypeNumber   = "number"
+	TypeString   = "string"
+)
+
+/*
+	Quantity is a number with a unit eg. 3d4h, 512Mi, 250m, 45%, 2021-10-12T10:00:00Z. The Value is in seconds for the
+	durations and the times (unix seconds), bytes for the sizes and cores for the cpu. The Raw is the input string, it
+	is retained so that the values which are not aggregated are displayed as is
+*/
+type Quantity struct {
+	Value  float64
+	Type   string
+	Binary bool
+	Raw    string
+}
+
+type TypeDef struct {
+	Cols *common.IntRange
+	Type string
+}
+
+// the number of the rows read ahead to infer the types of the columns
+const typeSampleRows = 1000
+
+var percentRegex = regexp.MustCompile(`^[+-]?[0-9]*\.?[0-9]+%$`)
+
+// the units are case sensitive, so that 5m is a duration and 5M is a size
+var sizeRegex = regexp.MustCompile(`^[0-9]*\.?[0-9]+\s?(?:[kKMGTPE]i?B?|B)$`)
+
+var cpuRegex = regexp.MustCompile(`^([0-9]*\.?[0-9]+)(m?)$`)
+
+var isoTimeRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+/*
+	types[4=duration,5=bytes,6=time]
+	types[AGE=duration,CPU=cpu,MEMORY=bytes,NAME=string]
+*/
+func extractTypeDefs(arg string) []TypeDef {
+	var defs []TypeDef
+	for _, part := range common.ParseIndexStr(arg) {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			fatalf("Invalid type '%v'. The format is <col>=<type>", part)
+		}
+		typ := strings.TrimSpace(kv[1])
+		if !isValidType(typ) {
+			fatalf("Unknown type '%v'. The types are duration, bytes, cpu, percent, time, number and string", typ)
+		}
+		defs = append(defs, TypeDef{Cols: common.ParseRange(strings.TrimSpace(kv[0])), Type: typ})
+	}
+	return defs
+}
+
+// resolveTypeDefs maps the column indices to the types
+func resolveTypeDefs(typeDefs []TypeDef, headers []string) map[int]string {
+	if len(typeDefs) == 0 {
+		return nil
+	}
+	types := make(map[int]string)
+	for _, def := range typeDefs {
+		resolveRange(def.Cols, headers, "types")
+		for _, index := range def.Cols.Indices {
+			types[index] = def.Type
+		}
+	}
+	return types
+}
+
+func isValidType(typ string) bool {
+	switch typ {
+	case TypeDuration, TypeBytes, TypeCpu, TypePercent, TypeTime, TypeNumber, TypeString:
+		return true
+	}
+	return false
+}
+
+// ConvertTyped converts the value based on the type. Only the numbers are converted if the type is empty
+func ConvertTyped(val string, typ string) interface{} {
+	switch typ {
+	case "":
+		return Convert(val)
+	case TypeString:
+		return val
+	case TypeNumber:
+		return convertNumber(val)
+	}
+	if qty, ok := parseQuantity(val, typ); ok {
+		return qty
+	}
+	return val
+}
+
+func convertNumber(val string) interface{} {
+	int64Val, err := strconv.ParseInt(val, 10, 64)
+	if err == nil {
+		return int64Val
+	}
+	float64Val, err := strconv.ParseFloat(val, 64)
+	if err == nil {
+		return float64Val
+	}
+	return val
+}
+
+/*
+	inferColTypes infers the types of the columns from the rows. A column is a quantity only if all the non empty values
+	are of the same type eg. 512Mi and 2Gi are bytes. The columns which mix the types eg. 250m and 1 are not converted
+	into the quantities, so a duration is never compared with a number
+*/
+func inferColTypes(rows [][]string) map[int]string {
+	inferrer := &typeInferrer{}
+	for _, words := range rows {
+		for i, word := range words {
+			inferrer.add(i, word)
+		}
+	}
+	return inferrer.types()
+}
+
+// inferRowTypes infers the types of the columns from the string values of the rows, the rest are already converted
+func inferRowTypes(rows []DataRow) map[int]string {
+	inferrer := &typeInferrer{}
+	for _, row := range rows {
+		for i, col := range row.Cols {
+			if str, ok := col.(string); ok {
+				inferrer.add(i, str)
+			}
+		}
+	}
+	return inferrer.types()
+}
+
+// typeInferrer holds the type of the values of each column seen so far, mixedType if the types differ
+type typeInferrer struct {
+	seen []string
+}
+
+const mixedType = "mixed"
+
+func (t *typeInferrer) add(index int, val string) {
+	if val == "" {
+		return
+	}
+	for len(t.seen) <= index {
+		t.seen = append(t.seen, "")
+	}
+	typ := TypeString
+	if _, isStr := convertNumber(val).(string); !isStr {
+		typ = TypeNumber
+	} else if qty, ok := inferQuantity(val); ok {
+		typ = qty.Type
+	}
+	if t.seen[index] == "" {
+		t.seen[index] = typ
+	} else if t.seen[index] != typ {
+		t.seen[index] = mixedType
+	}
+}
+
+// types returns the quantity types, the columns of the numbers, the strings and the mixed values are not included
+func (t *typeInferrer) types() map[int]string {
+	types := make(map[int]string)
+	for i, typ := range t.seen {
+		switch typ {
+		case "", TypeString, TypeNumber, mixedType:
+			continue
+		}
+		types[i] = typ
+	}
+	return types
+}
+
+// the values with the units start with a digit, so the rest of the strings are skipped quickly
+func inferQuantity(val string) (Quantity, bool) {
+	if val == "" || !(val[0] >= '0' && val[0] <= '9' || val[0] == '.' || val[0] == '+' || val[0] == '-') {
+		return Quantity{}, false
+	}
+	if percentRegex.MatchString(val) {
+		return parseQuantity(val, TypePercent)
+	}
+	if common.IsDuration(val) {
+		return parseQuantity(val, TypeDuration)
+	}
+	if sizeRegex.MatchString(val) {
+		return parseQuantity(val, TypeBytes)
+	}
+	if isoTimeRegex.MatchString(val) {
+		return parseQuantity(val, TypeTime)
+	}
+	return Quantity{}, false
+}
+
+func parseQuantity(val string, typ string) (Quantity, bool) {
+	str := strings.TrimSpace(val)
+	qty := Quantity{Type: typ, Raw: val}
+	switch typ {
+	case TypeDuration:
+		if num, err := strconv.ParseFloat(str, 64); err == nil {
+			qty.Value = num
+		} else if common.IsDuration(str) {
+			qty.Value = common.ParseDuration(str).Seconds()
+		} else {
+			return qty, false
+		}
+	case TypeBytes:
+		num, err := common.ParseBytes(str)
+		if err != nil {
+			return qty, false
+		}
+		qty.Value = num
+		qty.Binary = strings.Contains(str, "i")
+	case TypeCpu:
+		matches := cpuRegex.FindStringSubmatch(str)
+		if matches == nil {
+			return qty, false
+		}
+		num, _ := strconv.ParseFloat(matches[1], 64)
+		if matches[2] == "m" {
+			num = num / 1000
+		}
+		qty.Value = num
+	case TypePercent:
+		num, err := strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+		if err != nil {
+			return qty, false
+		}
+		qty.Value = num
+	case TypeTime:
+		t, err := common.ParseTime(str)
+		if err != nil {
+			return qty, false
+		}
+		qty.Value = unixSeconds(t)
+	default:
+		return qty, false
+	}
+	return qty, true
+}
+
+// String returns the input value, or the value in the human units for the computed values
+func (q Quantity) String() string {
+	if q.Raw != "" {
+		return q.Raw
+	}
+	switch q.Type {
+	case TypeDuration:
+		return formatDuration(q.Value)
+	case TypeBytes:
+		return formatBytes(q.Value, q.Binary)
+	case TypeCpu:
+		if q.Value == math.Trunc(q.Value) {
+			return formatNumber(q.Value)
+		}
+		return formatNumber(math.Round(q.Value*1000)) + "m"
+	case TypePercent:
+		return formatNumber(q.Value) + "%"
+	case TypeTime:
+		sec, frac := math.Modf(q.Value)
+		return time.Unix(int64(sec), int64(frac*float64(time.Second))).UTC().Format(time.RFC3339)
+	default:
+		return formatNumber(q.Value)
+	}
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+func (q Quantity) MarshalYAML() (interface{}, error) {
+	return q.String(), nil
+}
+
+// computed returns a new quantity of the same type without the raw value
+func (q Quantity) computed(value float64) Quantity {
+	return Quantity{Value: value, Type: q.Type, Binary: q.Binary}
+}
+
+// the times and the percents are not added up when the rows are grouped, eg. 50% + 70% is not 120%
+func isAdditive(typ string) bool {
+	return typ != TypeString && typ != TypeTime && typ != TypePercent
+}
+
+// the difference between two times is a duration
+func (q Quantity) delta(prev Quantity) Quantity {
+	if q.Type == TypeTime {
+		return Quantity{Value: q.Value - prev.Value, Type: TypeDuration}
+	}
+	return q.computed(q.Value - prev.Value)
+}
+
+// 93784 => 1d2h3m4s. The durations less than a second are shown as 500ms
+func formatDuration(seconds float64) string {
+	sign := ""
+	if seconds < 0 {
+		sign = "-"
+		seconds = -seconds
+	}
+	if seconds < 1 {
+		return sign + time.Duration(seconds*float64(time.Second)).String()
+	}
+	rem := int64(math.Round(seconds))
+	units := []struct {
+		suffix string
+		secs   int64
+	}{{"y", 365 * 86400}, {"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}}
+	var sb strings.Builder
+	for _, unit := range units {
+		if rem >= unit.secs {
+			sb.WriteString(fmt.Sprintf("%d%v", rem/unit.secs, unit.suffix))
+			rem = rem % unit.secs
+		}
+	}
+	return sign + sb.String()
+}
+
+// 536870912 => 512Mi for the binary units, 1500000000 => 1.5G for the SI units
+func formatBytes(value float64, binary bool) string {
+	base := 1000.0
+	suffixes := []string{"k", "M", "G", "T", "P", "E"}
+	if binary {
+		base = 1024.0
+		suffixes = []string{"Ki", "Mi", "Gi", "Ti", "Pi", "Ei"}
+	}
+	abs := math.Abs(value)
+	if abs < base {
+		return formatNumber(value)
+	}
+	unit := ""
+	for _, suffix := range suffixes {
+		if abs < base {
+			break
+		}
+		abs = abs / base
+		value = value / base
+		unit = suffix
+	}
+	return formatNumber(value) + unit
+}
+
+// the numbers are rounded to 2 decimals, the trailing zeros are removed
+func formatNumber(value float64) string {
+	str := strconv.FormatFloat(value, 'f', 2, 64)
+	str = strings.TrimRight(str, "0")
+	return strings.TrimSuffix(str, ".")
+}
+
+// the numeric value for the expressions. The times are retained as the strings, those can be used with the
+// time functions eg. age([6]), parse_time([6])
+func exprValue(val interface{}) interface{} {
+	if qty, ok := val.(Quantity); ok {
+		if qty.Type == TypeTime {
+			return qty.String()
+		}
+		return qty.Value
+	}
+	return val
+}
+
+// colType returns the type of the input column set by types[], else the type inferred from the first rows
+func colType(csvFmt *CsvFormat, index int) string {
+	if typ := csvFmt.ColTypes[index]; typ != "" {
+		return typ
+	}
+	return csvFmt.InTypes[index]
+}
+
+// colTypes returns the types of the input columns, the types[] over the inferred types
+func colTypes(csvFmt *CsvFormat) map[int]string {
+	types := make(map[int]string)
+	for index, typ := range csvFmt.InTypes {
+		types[index] = typ
+	}
+	for index, typ := range csvFmt.ColTypes {
+		types[index] = typ
+	}
+	return types
+}
+
+/*
+	outputType returns the type of the output column set by types[], else the type inferred from the output rows. The
+	rows which are written as they are read have the same columns as the input
+*/
+func outputType(csvFmt *CsvFormat, index int) string {
+	if typ := csvFmt.ColTypes[index]; typ != "" {
+		return typ
+	}
+	if csvFmt.OutTypes != nil {
+		return csvFmt.OutTypes[index]
+	}
+	return csvFmt.InTypes[index]
+}
+
+func convertCol(csvFmt *CsvFormat, index int, val interface{}) interface{} {
+	if str, ok := val.(string); ok {
+		return ConvertTyped(str, outputType(csvFmt, index))
+	}
+	return ConvertIfNeeded(val)
+}
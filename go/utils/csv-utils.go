@@ -2,13 +2,11 @@ package utils
 
 import (
 	"bufio"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/Knetic/govaluate"
 	"github.com/abeytom/utilbox/common"
-	"gopkg.in/yaml.v2"
 	"io"
 	"log"
 	"os"
@@ -38,10 +36,13 @@ type CsvInjectArgs struct {
 
 type CsvFormat struct {
 	ColFmtMap    map[int][]ColumnFormat
+	ColFmtNames  map[string][]ColumnFormat
 	ColExt       *common.IntRange
 	RowExt       *common.IntRange
 	Split        string
 	MaxSplit     int
+	KvBlockDef   *KvBlockDef
+	CsvDialect   *CsvDialect
 	Merge        string
 	IsLMerge     bool
 	LMerge       string
@@ -57,12 +58,40 @@ type CsvFormat struct {
 	CalcDefs     []CalcDef
 	KeyDef       *HeaderDef
 	Filter       *Filter
+	Having       *Filter
+	MemLimit     int64
+	JoinDef      *JoinDef
+	PivotDef     *PivotDef
+	UnpivotDef   *UnpivotDef
+	WindowDef    *WindowDef
+	DiffDef      *DiffDef
+	UniqDef      *UniqDef
+	TopDef       *TopDef
+	Limit        int
+	Tail         int
+	Stats        bool
+	BucketDef    *BucketDef
+	TypeDefs     []TypeDef
+	ColTypes     map[int]string
+	ColRefs      []colRef
+	InTypes      map[int]string
+	OutTypes     map[int]string
+	Writer       io.Writer
+}
+
+// out returns the writer of the output, the stdout by default
+func (c *CsvFormat) out() io.Writer {
+	if c.Writer == nil {
+		return os.Stdout
+	}
+	return c.Writer
 }
 
 type GroupByDef struct {
 	Sum        string //deprecated
 	ColIndices *common.IntRange
 	ShowCount  bool
+	Aggs       []AggDef
 	//SortDef *SortDef
 }
 
@@ -74,8 +103,12 @@ type SortDef struct {
 type OutputDef struct {
 	Type string
 	//Fields []string
-	Levels  int
-	Flatten bool
+	Levels   int
+	Flatten  bool
+	Bins     int
+	Style    string
+	ColWidth int
+	Wrap     bool
 }
 
 type HeaderDef struct {
@@ -83,11 +116,13 @@ type HeaderDef struct {
 }
 
 type CalcDef struct {
+	Arg        string
 	RawExpr    string
 	ParsedExpr string
 	Indices    *common.IntSet
 	EvalExpr   *govaluate.EvaluableExpression
 	FieldName  string
+	Names      []string
 }
 
 type OutputData struct {
@@ -97,8 +132,11 @@ type OutputData struct {
 }
 
 type Filter struct {
-	ExprStr string
-	Expr    *govaluate.EvaluableExpression
+	ExprStr    string
+	Expr       *govaluate.EvaluableExpression
+	wExpr      *ExprWrap
+	colIndices map[string]int
+	types      map[int]string
 }
 
 func CsvParse(args []string) {
@@ -107,27 +145,55 @@ func CsvParse(args []string) {
 		log.Fatal(errors.New("there is no data to read from STDIN"))
 		return
 	}
-	csvFmt := parseCsvArgs(args)
+	defer exitOnError()
+	processInput(parseCsvArgs(args), os.Stdin)
+}
+
+// ParseCsvArgs parses the flags of the csv command. The column names are resolved with the headers while the
+// input is processed, so a format is used for a single input
+func ParseCsvArgs(args []string) (csvFmt *CsvFormat, err error) {
+	defer recoverError(&err)
+	return parseCsvArgs(args), nil
+}
+
+// ProcessCsv reads the lines from the reader and writes the output to the Writer of the format
+func ProcessCsv(csvFmt *CsvFormat, reader io.Reader) (err error) {
+	defer recoverError(&err)
+	processInput(csvFmt, reader)
+	return nil
+}
+
+// processInput splits the lines into the columns based on the split of the format
+func processInput(csvFmt *CsvFormat, reader io.Reader) {
 	if csvFmt.Split == "csv" {
-		processCsv(csvFmt)
-	} else {
-		scanner := bufio.NewScanner(os.Stdin)
-		processor := NewLineProcessor(csvFmt)
-		useFields := csvFmt.Split == "space+"
-		for scanner.Scan() {
-			processor.processRow(func() []string {
-				if useFields {
-					return strings.Fields(scanner.Text())
-				}
-				if csvFmt.MaxSplit > 0 {
-					return common.DelBlankItems(strings.SplitN(scanner.Text(), csvFmt.Split, csvFmt.MaxSplit))
-				}
-				return common.DelBlankItems(strings.Split(scanner.Text(), csvFmt.Split))
-			})
-		}
-		processLines(csvFmt, processor.Lines, processor.DataHeaders)
-		processor.Close()
+		processCsv(csvFmt, reader)
+		return
+	} else if csvFmt.Split == "columns" {
+		processColumns(csvFmt, reader)
+		return
+	} else if csvFmt.Split == "kvblock" {
+		processKvBlocks(csvFmt, reader)
+		return
+	}
+	scanner := bufio.NewScanner(reader)
+	processor := NewLineProcessor(csvFmt)
+	defer processor.Close()
+	useFields := csvFmt.Split == "space+"
+	for scanner.Scan() {
+		processor.processRow(func() []string {
+			if useFields {
+				return strings.Fields(scanner.Text())
+			}
+			if csvFmt.MaxSplit > 0 {
+				return common.DelBlankItems(strings.SplitN(scanner.Text(), csvFmt.Split, csvFmt.MaxSplit))
+			}
+			return common.DelBlankItems(strings.Split(scanner.Text(), csvFmt.Split))
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf("Unable to read the input. The error is %v", err)
 	}
+	processLines(csvFmt, processor)
 }
 
 func parseCsvArgs(args []string) *CsvFormat {
@@ -144,7 +210,12 @@ func parseCsvArgs(args []string) *CsvFormat {
 }
 
 func doParseCsvArgs(args []string, csvFmt *CsvFormat) *CsvFormat {
+	args = expandRecipes(args)
 	for _, arg := range args {
+		if strings.TrimSpace(arg) == "" {
+			continue
+		}
+		validateFlag(arg)
 		if strings.HasPrefix(arg, "lmerge") {
 			csvFmt.IsLMerge = true
 			if arg != "lmerge" {
@@ -156,11 +227,19 @@ func doParseCsvArgs(args []string, csvFmt *CsvFormat) *CsvFormat {
 			csvFmt.RowExt = extractCsvIndexArg(arg)
 		} else if strings.Index(arg, "col[") == 0 {
 			csvFmt.ColExt = extractCsvIndexArg(arg)
+			csvFmt.ColRefs = append(csvFmt.ColRefs, bracketRefs(inputScope, "col", arg, 0)...)
 		} else if strings.Index(arg, "ncol[") == 0 {
 			csvFmt.ColExt = extractCsvIndexArg(arg)
 			csvFmt.ColExt.Exclude = true
+			csvFmt.ColRefs = append(csvFmt.ColRefs, bracketRefs(inputScope, "ncol", arg, 0)...)
 		} else if strings.Index(arg, "sort[") == 0 {
 			csvFmt.SortDef = extractSort(arg)
+		} else if strings.Index(arg, "split:csv..") == 0 {
+			csvFmt.Split = "csv"
+			csvFmt.CsvDialect = extractCsvDialect(arg)
+		} else if strings.Index(arg, "split:kvblock") == 0 {
+			csvFmt.Split = "kvblock"
+			csvFmt.KvBlockDef = extractKvBlockDef(arg)
 		} else if strings.Index(arg, "split:") == 0 {
 			delim, count := processSplitArgs(arg)
 			csvFmt.Split = delim
@@ -187,6 +266,35 @@ func doParseCsvArgs(args []string, csvFmt *CsvFormat) *CsvFormat {
 			csvFmt.KeyDef = def
 		} else if strings.HasPrefix(arg, "filter") {
 			extractFilterDef(arg, csvFmt)
+		} else if strings.HasPrefix(arg, "having") {
+			extractHavingDef(arg, csvFmt)
+		} else if strings.Index(arg, "mem:") == 0 {
+			csvFmt.MemLimit = parseMemSize(arg)
+		} else if strings.Index(arg, "join[") == 0 {
+			csvFmt.JoinDef = extractJoinDef(arg)
+		} else if strings.Index(arg, "pivot[") == 0 {
+			csvFmt.PivotDef = extractPivotDef(arg)
+		} else if strings.Index(arg, "unpivot[") == 0 {
+			csvFmt.UnpivotDef = extractUnpivotDef(arg)
+		} else if strings.Index(arg, "win") == 0 {
+			csvFmt.WindowDef = extractWindowDef(arg)
+		} else if strings.Index(arg, "diff") == 0 {
+			csvFmt.DiffDef = extractDiffDef(arg)
+		} else if strings.Index(arg, "uniq") == 0 {
+			csvFmt.UniqDef = extractUniqDef(arg)
+		} else if strings.Index(arg, "top[") == 0 {
+			csvFmt.TopDef = extractTopDef(arg)
+		} else if strings.Index(arg, "limit:") == 0 {
+			csvFmt.Limit = extractRowCount(arg, "limit:")
+		} else if strings.Index(arg, "tail:") == 0 {
+			csvFmt.Tail = extractRowCount(arg, "tail:")
+		} else if arg == "stats" {
+			csvFmt.Stats = true
+		} else if strings.Index(arg, "bucket[") == 0 {
+			csvFmt.BucketDef = extractBucketDef(arg)
+			csvFmt.ColRefs = append(csvFmt.ColRefs, bracketRefs(dataScope, "bucket", arg, 1)...)
+		} else if strings.Index(arg, "types[") == 0 {
+			csvFmt.TypeDefs = extractTypeDefs(arg)
 		}
 	}
 	if csvFmt.NoHeaderIn {
@@ -194,33 +302,56 @@ func doParseCsvArgs(args []string, csvFmt *CsvFormat) *CsvFormat {
 			csvFmt.NoHeaderOut = true
 		}
 	}
+	checkStats(csvFmt)
 	csvFmt.HasWholeOpr = hasWholeOpr(csvFmt)
 	csvFmt.HasMrReducer = hasMrReducer(csvFmt.MapRed)
 	return csvFmt
 }
 
-func processCsv(csvFmt *CsvFormat) {
-	reader := csv.NewReader(bufio.NewReader(os.Stdin))
+func processCsv(csvFmt *CsvFormat, in io.Reader) {
+	reader := newCsvReader(in, csvFmt.CsvDialect)
 	processor := NewLineProcessor(csvFmt)
+	defer processor.Close()
 	for {
-		words, _ := reader.Read()
+		words := readCsvRecord(reader, csvFmt.CsvDialect)
 		if words == nil {
 			break
 		}
 		processor.processRow(func() []string { return words })
 	}
-	processLines(csvFmt, processor.Lines, processor.DataHeaders)
-	processor.Close()
+	processLines(csvFmt, processor)
 }
 
-func processLines(csvFmt *CsvFormat, lines [][]string, dataHeaders []string) {
-	if len(lines) <= 0 {
+func processLines(csvFmt *CsvFormat, processor *LineProcessor) {
+	processor.Finish()
+	dataHeaders := processor.headers()
+	if csvFmt.Stats {
+		if processor.profiler == nil {
+			processor.profiler = NewColumnProfiler(dataHeaders, colTypes(csvFmt))
+		}
+		processOutput(csvFmt, processor.profiler.ToDataRows())
 		return
 	}
-	if csvFmt.HeaderDef != nil && csvFmt.HeaderDef.Fields != nil {
-		dataHeaders = csvFmt.HeaderDef.Fields
+	if processor.sorter != nil {
+		processor.sorter.WriteSorted(dataHeaders)
+		return
+	}
+	var data *DataRows
+	if processor.groupMap != nil {
+		data = processor.groupMap.ToDataRows(dataHeaders)
+	} else if len(processor.Lines) > 0 {
+		data = applyGroupBy(csvFmt, processor.Lines, dataHeaders)
+	} else if csvFmt.DiffDef != nil {
+		//all the rows of the snapshot are removed
+		data = &DataRows{Headers: dataHeaders}
+	} else {
+		return
+	}
+	data = applyHaving(csvFmt, data)
+	data = applyReshape(csvFmt, data)
+	if csvFmt.DiffDef != nil {
+		data = applyDiff(csvFmt, data)
 	}
-	data := applyGroupBy(csvFmt, lines, dataHeaders)
 	processOutput(csvFmt, data)
 }
 
@@ -232,21 +363,34 @@ type DataRows struct {
 }
 
 func processOutput(csvFmt *CsvFormat, data *DataRows) {
+	csvFmt.OutTypes = inferRowTypes(data.DataRows)
+	resolveCalcRefs(csvFmt, data.Headers)
+	if data.Headers != nil {
+		checkColRefs(csvFmt, calcScope, len(data.Headers))
+	}
 	dataRows := applyCalcAll(csvFmt, data.DataRows)
+	headers := applyCalcHeaders(csvFmt, data.Headers)
 
 	if csvFmt.SortDef != nil {
+		resolveRange(csvFmt.SortDef.SortCols, headers, "sort")
+		if len(dataRows) > 0 {
+			checkIndices(csvFmt.SortDef.SortCols, MaxInt(len(headers), len(dataRows[0].Cols)), "sort")
+		}
 		if data.Converted {
 			dataRows = applySort(csvFmt, dataRows)
 		} else {
 			dataRows = convertAndApplySort(csvFmt, dataRows)
 		}
 	}
+	if csvFmt.WindowDef != nil {
+		dataRows, headers = applyWindow(csvFmt, dataRows, headers)
+	}
+	dataRows, headers = applySelect(csvFmt, dataRows, headers)
 	if csvFmt.IsLMerge {
 		processLMergeOutput(csvFmt, dataRows)
 		return
 	}
 
-	headers := applyCalcHeaders(csvFmt, data.Headers)
 	def := csvFmt.OutputDef
 	flatten := def != nil && def.Flatten
 	if flatten {
@@ -257,9 +401,25 @@ func processOutput(csvFmt *CsvFormat, data *DataRows) {
 	} else if def.Type == "json" {
 		processJsonOutput(dataRows, csvFmt, headers, data.GroupByCount)
 	} else if def.Type == "table" {
-		ProcessTableOutput(dataRows, csvFmt, headers, os.Stdout)
+		ProcessTableOutput(dataRows, csvFmt, headers, csvFmt.out())
 	} else if def.Type == "kv" {
 		processKvOutput(dataRows, csvFmt, headers)
+	} else if def.Type == "md" {
+		processMarkdownOutput(dataRows, headers, csvFmt.out())
+	} else if def.Type == "html" {
+		processHtmlOutput(dataRows, csvFmt, headers, csvFmt.out())
+	} else if def.Type == "yaml" {
+		processYamlOutput(dataRows, headers, csvFmt.out())
+	} else if def.Type == "jsonl" {
+		processJsonLinesOutput(dataRows, headers, csvFmt.out())
+	} else if def.Type == "tsv" {
+		processTsvOutput(dataRows, csvFmt, headers, csvFmt.out())
+	} else if def.Type == "view" {
+		processViewOutput(dataRows, csvFmt, headers)
+	} else if def.Type == "bar" {
+		processBarOutput(dataRows, csvFmt, headers, data.GroupByCount)
+	} else if def.Type == "hist" {
+		processHistOutput(dataRows, csvFmt, headers)
 	} else {
 		processCsvOutput(dataRows, csvFmt, headers)
 	}
@@ -355,7 +515,7 @@ func processKvOutput(rows []DataRow, csvFmt *CsvFormat, headers []string) {
 		}
 		value := strings.TrimSpace(strings.Join(values, ","))
 		if len(value) > 0 {
-			fmt.Printf("%v%v%v\n", key, merge, value)
+			fmt.Fprintf(csvFmt.out(), "%v%v%v\n", key, merge, value)
 		}
 	}
 }
@@ -394,7 +554,7 @@ func processLMergeOutput(csvFmt *CsvFormat, dataRows []DataRow) {
 		}
 		lines = append(lines, strings.Join(words, merge))
 	}
-	fmt.Printf("%s\n", strings.Join(lines, csvFmt.LMerge))
+	fmt.Fprintf(csvFmt.out(), "%s\n", strings.Join(lines, csvFmt.LMerge))
 }
 
 //func applyRowSum(csvFmt *CsvFormat, lines [][]string, inHeaders []string) {
@@ -460,7 +620,7 @@ func pickWords(words []string, indices []int) []string {
 }
 
 func applyGroupBy(csvFmt *CsvFormat, lines [][]string, defHeaders []string) *DataRows {
-	if csvFmt.MapRed == nil || csvFmt.MapRed.ColIndices == nil {
+	if !isGroupBy(csvFmt) {
 		return &DataRows{
 			DataRows:     toDataRows(lines),
 			Headers:      defHeaders,
@@ -468,52 +628,15 @@ func applyGroupBy(csvFmt *CsvFormat, lines [][]string, defHeaders []string) *Dat
 			Converted:    false,
 		}
 	}
-	firstRow := lines[0]
-	//compute the keyIndices
-	groupBy := csvFmt.MapRed.ColIndices
-	keyIndices := common.GetFilterStrIndices(common.ApplyRange(firstRow, groupBy))
-	//compute valueIndices
-	var valueIndices []int
-	for i := 0; i < len(firstRow); i++ {
-		if !common.BruteIntContains(keyIndices, i) {
-			valueIndices = append(valueIndices, i)
-		}
-	}
-	groupMap := GroupMap{
-		KeyIndices:   keyIndices,
-		ValueIndices: valueIndices,
-		CsvFormat:    csvFmt,
-	}
+	groupMap := NewGroupMap(csvFmt, defHeaders, lines[0])
 	for _, words := range lines {
-		keys := pickWords(words, keyIndices)
-		values := pickWords(words, valueIndices)
-		groupMap.Put(keys, values)
-	}
-	headers := applyGroupByHeaders(csvFmt, defHeaders, keyIndices)
-	return &DataRows{
-		DataRows:     groupMap.PostProcess(),
-		Headers:      headers,
-		GroupByCount: len(keyIndices),
-		Converted:    true,
+		groupMap.Add(words)
 	}
+	return groupMap.ToDataRows(defHeaders)
+}
 
-	//dataRows := applyCalcAll(csvFmt, groupMap.PostProcess())
-	//if csvFmt.SortDef != nil {
-	//	applySort(dataRows, csvFmt)
-	//}
-
-	//headers = applyCalcHeaders(csvFmt, headers)
-	//if csvFmt.OutputDef != nil {
-	//	if csvFmt.OutputDef.Type == "json" {
-	//		processJsonOutput(dataRows, csvFmt, headers, len(keyIndices))
-	//	} else if csvFmt.OutputDef.Type == "table" {
-	//		ProcessTableOutput(dataRows, csvFmt, headers)
-	//	} else {
-	//		printCsv(csvFmt, headers, dataRows)
-	//	}
-	//	return
-	//}
-	//printCsv(csvFmt, headers, dataRows)
+func isGroupBy(csvFmt *CsvFormat) bool {
+	return csvFmt.MapRed != nil && csvFmt.MapRed.ColIndices != nil
 }
 
 func printCsv(csvFmt *CsvFormat, headers []string, dataRows []DataRow) {
@@ -525,107 +648,6 @@ func printCsv(csvFmt *CsvFormat, headers []string, dataRows []DataRow) {
 	writer.Close()
 }
 
-func ProcessTableOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, writer io.Writer) {
-	//convert the object data model into yaml first
-	for _, row := range rows {
-		for i, col := range row.Cols {
-			switch col.(type) {
-			case map[string]interface{}, map[interface{}]interface{}, []interface{}, []map[string]string:
-				bytes, err := yaml.Marshal(col)
-				if err != nil {
-					row.Cols[i] = fmt.Sprintf("%v", col)
-				} else {
-					yamlStr := string(bytes)
-					lines := strings.Split(yamlStr, "\n")
-					row.Cols[i] = common.NewOrderedStringSet(lines)
-				}
-			}
-		}
-	}
-
-	colWidths := make(map[int]int)
-	for _, row := range rows {
-		for i, col := range row.Cols {
-			var width int
-			switch col.(type) {
-			case common.StringCol:
-				for _, val := range col.(common.StringCol).Values() {
-					if (len(val)) > width {
-						width = len(val)
-					}
-				}
-			default:
-				width = len(common.ToString(col))
-			}
-			existing := colWidths[i]
-			if width > existing {
-				colWidths[i] = width
-			}
-		}
-	}
-	for i, header := range headers {
-		existing := colWidths[i]
-		if len(header) > existing {
-			colWidths[i] = len(header)
-		}
-	}
-	fmtMap := make(map[int]string)
-	for k, v := range colWidths {
-		fmtMap[k] = "%-" + strconv.Itoa(v+3) + "s "
-	}
-	if !csvFmt.NoHeaderOut {
-		for i, header := range headers {
-			fmt.Fprintf(writer, fmtMap[i], header)
-		}
-		fmt.Fprintln(writer, "")
-	}
-
-	for _, row := range rows {
-		extraCols := make([][]string, len(row.Cols))
-		extraValCount := 0
-		for i, col := range row.Cols {
-			switch col.(type) {
-			case common.StringCol:
-				vals := col.(common.StringCol).Values()
-				if len(vals) == 0 {
-					fmt.Fprintf(writer, fmtMap[i], "")
-				} else if len(vals) == 1 {
-					fmt.Fprintf(writer, fmtMap[i], common.ToString(vals[0]))
-				} else {
-					fmt.Fprintf(writer, fmtMap[i], common.ToString(vals[0]))
-					extraColVals := make([]string, len(vals)-1)
-					for i := 1; i < len(vals); i++ {
-						extraColVals[i-1] = vals[i]
-					}
-					extraCols[i] = extraColVals
-					if extraValCount < len(extraColVals) {
-						extraValCount = len(extraColVals)
-					}
-				}
-			default:
-				if col != nil {
-					fmt.Fprintf(writer, fmtMap[i], common.ToString(col))
-				} else {
-					fmt.Fprintf(writer, fmtMap[i], "")
-				}
-			}
-		}
-		fmt.Fprintln(writer, "")
-		if len(extraCols) > 0 {
-			for i := 0; i < extraValCount; i++ {
-				for j, vals := range extraCols {
-					if len(vals) <= i {
-						fmt.Fprintf(writer, fmtMap[j], "")
-					} else {
-						fmt.Fprintf(writer, fmtMap[j], vals[i])
-					}
-				}
-				fmt.Fprintln(writer, "")
-			}
-		}
-	}
-}
-
 func applyGroupByHeaders(csvFmt *CsvFormat, headers []string, keyIndices []int) []string {
 	if csvFmt.NoHeaderOut {
 		return []string{}
@@ -660,17 +682,12 @@ func applyCalcHeaders(csvFmt *CsvFormat, headers []string) []string {
 	if len(calcDefs) == 0 {
 		return headers
 	}
-	var calcHeaders []string
-	for _, calcDef := range calcDefs {
-		indexSet := calcDef.Indices
-		indexVals := indexSet.Values()
-		headExpr := calcDef.ParsedExpr
-		for _, index := range indexVals {
-			headExpr = strings.Replace(headExpr, fmt.Sprintf("col%d", index), headers[index], 1)
-		}
-		calcHeaders = append(calcHeaders, headExpr)
+	var nHeaders []string
+	nHeaders = append(nHeaders, headers...)
+	for i := range calcDefs {
+		nHeaders = append(nHeaders, calcHeader(&calcDefs[i], nHeaders))
 	}
-	return append(headers, calcHeaders...)
+	return nHeaders
 
 	//indexSet := calcDef.Indices
 	//indexVals := indexSet.Values()
@@ -710,15 +727,16 @@ func applyCalc(csvFmt *CsvFormat, row *DataRow) *DataRow {
 	if len(calcDefs) == 0 {
 		return row
 	}
-	var calcValues []interface{}
+	//a calc can refer to the values of the preceding calc
+	cols := row.Cols
 	for _, calcDef := range calcDefs {
 		params := govaluate.MapParameters{}
 		indexSet := calcDef.Indices
-		for i, col := range row.Cols {
+		for i, col := range cols {
 			if indexSet.Contains(i) {
 				key := fmt.Sprintf("col%d", i)
 				//todo if any args are string, then dont convert into number
-				params[key] = ConvertIfNeeded(col)
+				params[key] = exprValue(convertCol(csvFmt, i, col))
 				//fmt.Printf("%T:%v\n", params[key], params[key])
 			}
 		}
@@ -734,9 +752,9 @@ func applyCalc(csvFmt *CsvFormat, row *DataRow) *DataRow {
 			}
 		}
 		//fmt.Printf("EVAL: %T:%v\n", eval, eval)
-		calcValues = append(calcValues, eval)
+		cols = append(cols, eval)
 	}
-	row.Cols = append(row.Cols, calcValues...)
+	row.Cols = cols
 	return row
 
 	//indices := calcDef.Indices
@@ -815,14 +833,14 @@ func processJsonOutput(rows []DataRow, csvFmt *CsvFormat, headers []string, grou
 			}
 			array = append(array, colMap)
 		}
-		printJson(array)
+		printJson(array, csvFmt.out())
 	} else {
 		outMap := make(map[string]map[string]interface{})
 		for _, row := range rows {
 			processJsonLevel(&row, 0, fields, outMap)
 		}
 		//unwrap Json
-		printJson(unwrapJsonMap(0, levels, outMap))
+		printJson(unwrapJsonMap(0, levels, outMap), csvFmt.out())
 	}
 }
 
@@ -850,12 +868,12 @@ func calculateOutputFields(headers []string, levels int, keyCount int) []string
 	return nHeaders
 }
 
-func printJson(array []map[string]interface{}) {
+func printJson(array []map[string]interface{}, writer io.Writer) {
 	buf, err := json.Marshal(array)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(writer, err)
 	}
-	fmt.Printf("%s\n", buf)
+	fmt.Fprintf(writer, "%s\n", buf)
 }
 
 func unwrapJsonMap(level int, levels int, dataMap map[string]map[string]interface{}) []map[string]interface{} {
@@ -928,6 +946,9 @@ func toDataRows(lines [][]string) []DataRow {
 }
 
 func convertAndApplySort(csvFmt *CsvFormat, rows []DataRow) []DataRow {
+	if len(rows) == 0 {
+		return nil
+	}
 	firstRow := rows[0]
 	sortDef := csvFmt.SortDef
 	sortCols := sortDef.SortCols
@@ -941,7 +962,7 @@ func convertAndApplySort(csvFmt *CsvFormat, rows []DataRow) []DataRow {
 		var nWords []interface{}
 		for i, word := range row.Cols {
 			if _, exists := indexMap[i]; exists {
-				nWords = append(nWords, ConvertIfNeeded(word))
+				nWords = append(nWords, convertCol(csvFmt, i, word))
 			} else {
 				nWords = append(nWords, word)
 			}
@@ -974,7 +995,9 @@ func applySort(csvFmt *CsvFormat, rows []DataRow) []DataRow {
 }
 
 func hasWholeOpr(csvFmt *CsvFormat) bool {
-	if csvFmt.IsLMerge || csvFmt.MapRed != nil || HasNonCsvOutputFmt(csvFmt) || csvFmt.SortDef != nil {
+	if csvFmt.IsLMerge || csvFmt.MapRed != nil || HasNonCsvOutputFmt(csvFmt) || csvFmt.SortDef != nil ||
+		csvFmt.PivotDef != nil || csvFmt.UnpivotDef != nil || csvFmt.WindowDef != nil ||
+		csvFmt.DiffDef != nil || hasSelect(csvFmt) || csvFmt.Stats {
 		return true
 	}
 	return false
@@ -1005,6 +1028,30 @@ func parseInlineCommand(cmd string, cmdline string) []string {
 	return strings.Split(cmdline, string(sep))
 }
 
+/*
+	join[owners.csv,left=0,2,type=left] => [owners.csv], {left: [0, 2], type: [left]}
+
+	the values without a name are appended to the previous option, so an option value can be a list
+*/
+func parseIndexOptions(arg string) ([]string, map[string][]string) {
+	var positional []string
+	opts := make(map[string][]string)
+	var name string
+	for _, part := range common.ParseIndexStr(arg) {
+		part = strings.TrimSpace(part)
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			name = kv[0]
+			opts[name] = append(opts[name], kv[1])
+		} else if name != "" {
+			opts[name] = append(opts[name], part)
+		} else {
+			positional = append(positional, part)
+		}
+	}
+	return positional, opts
+}
+
 func extractHeaderDef(arg string) *HeaderDef {
 	parts := common.ParseSubCommandArg(arg)
 	def := &HeaderDef{}
@@ -1013,70 +1060,102 @@ func extractHeaderDef(arg string) *HeaderDef {
 }
 
 func extractCalcDef(arg string, csvFmt *CsvFormat) {
-	def := CalcDef{}
+	def := CalcDef{Arg: arg}
 	rawExpr := common.ParseExprStr(arg)
-	modifiedExpr := rawExpr
-	var indices common.IntSet
-	for i := 0; i < 100; i++ {
-		idxVar := fmt.Sprintf("[%d]", i)
-		if strings.Index(rawExpr, idxVar) != -1 {
-			indices.Add(i)
-			modifiedExpr = strings.Replace(modifiedExpr, idxVar,
-				fmt.Sprintf("col%d", i), 1)
-		}
-	}
 	def.RawExpr = rawExpr
-	def.ParsedExpr = modifiedExpr
-	def.Indices = &indices
-	expr, err := govaluate.NewEvaluableExpression(def.ParsedExpr)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ERR: Expr eval failed %v", err)
+	csvFmt.ColRefs = append(csvFmt.ColRefs, exprRefs(calcScope, "calc", arg, len(csvFmt.CalcDefs))...)
+	def.ParsedExpr, def.Indices, def.Names = parseCalcExpr(rawExpr)
+	if len(def.Names) > 0 {
+		//the expression is parsed once the names are resolved with the headers
+		csvFmt.CalcDefs = append(csvFmt.CalcDefs, def)
 		return
 	}
+	expr, err := newEvaluableExpression(def.ParsedExpr)
+	if err != nil {
+		argError(arg, calcExprPos(arg), "Invalid calc(%v). The error is %v", rawExpr, err)
+	}
 	def.EvalExpr = expr
 	csvFmt.CalcDefs = append(csvFmt.CalcDefs, def)
 }
 
+// the position of the expression in calc(..)
+func calcExprPos(arg string) int {
+	return strings.Index(arg, "(") + 1
+}
+
 func extractFilterDef(arg string, csvFmt *CsvFormat) {
 	parts := parseInlineCommand("filter", arg)
-	exprStr := parts[1]
-	filter := &Filter{ExprStr: exprStr}
-	expr, err := govaluate.NewEvaluableExpression(exprStr)
-	if err != nil {
-		log.Fatalf("Invalid expression %v. The error is %v", exprStr, err)
-	}
-	filter.Expr = expr
-	csvFmt.Filter = filter
+	csvFmt.Filter = newFilter(parts[1])
+	csvFmt.ColRefs = append(csvFmt.ColRefs, exprRefs(dataScope, "filter", arg, 0)...)
 }
 
 func processOutputArgs(command string, c *CsvFormat) {
 	parts := parseInlineCommand("out", command)
 	def := OutputDef{}
 	def.Type = parts[1]
+	if common.IndexOf(outputTypes, def.Type) < 0 {
+		if match := common.ClosestMatch(def.Type, outputTypes); match != "" {
+			fatalf("Unknown output '%v', did you mean '%v'?", def.Type, match)
+		}
+		fatalf("Unknown output '%v'. The outputs are %v", def.Type, strings.Join(outputTypes, ", "))
+	}
 	for _, arg := range parts[2:] {
 		//if strings.Index(arg, "fields[") == 0 {
 		//	fields := common.ParseSubCommandArg(arg)
 		//	def.Fields = common.ParseIndexStr(fields[0])
 		//} else
-		if strings.Index(arg, "levels:") != -1 {
+		if strings.Index(arg, "levels:") == 0 {
 			levels, err := strconv.Atoi(extractArg(arg, "levels:"))
 			if err == nil {
 				def.Levels = levels
 			}
-		}
-		if arg == "flatten" {
+		} else if arg == "flatten" {
 			def.Flatten = true
+		} else if strings.Index(arg, "bins:") == 0 {
+			bins, err := strconv.Atoi(extractArg(arg, "bins:"))
+			if err != nil || bins <= 0 {
+				fatalf("Invalid bins '%v' in '%v'. A positive number is expected eg. out..hist..bins:20", arg[5:], command)
+			}
+			def.Bins = bins
+		} else if strings.Index(arg, "style:") == 0 {
+			def.Style = arg[6:]
+			if _, ok := tableStyles[def.Style]; !ok {
+				fatalf("Unknown table style '%v'. The styles are %v", def.Style, strings.Join(tableStyleNames, ", "))
+			}
+		} else if strings.Index(arg, "colwidth:") == 0 {
+			width, err := strconv.Atoi(arg[9:])
+			if err != nil || width <= 0 {
+				fatalf("Invalid colwidth '%v' in '%v'. A positive number is expected eg. out..table..colwidth:40",
+					arg[9:], command)
+			}
+			def.ColWidth = width
+		} else if arg == "wrap" {
+			def.Wrap = true
+		} else if _, ok := tableStyles[arg]; ok {
+			fatalf("Unknown output option '%v' in '%v', did you mean 'style:%v'?", arg, command, arg)
+		} else if match := common.ClosestMatch(arg, outputOptions); match != "" {
+			fatalf("Unknown output option '%v' in '%v', did you mean '%v'?", arg, command, match)
+		} else {
+			fatalf("Unknown output option '%v' in '%v'. The options are %v", arg, command,
+				strings.Join(outputOptions, ", "))
 		}
 	}
 	c.OutputDef = &def
 }
 
+var outputTypes = []string{"csv", "json", "table", "kv", "md", "html", "yaml", "jsonl", "tsv", "view", "bar",
+	"hist"}
+
+var outputOptions = []string{"levels:", "flatten", "bins:", "style:", "colwidth:", "wrap"}
+
 func processGroupArgs(command string, csvFmt *CsvFormat) {
 	args := common.ParseSubCommandArg(command)
 	mapRed := GroupByDef{}
 	for _, part := range args {
 		if strings.Index(part, "group[") != -1 {
 			mapRed.ColIndices = extractCsvIndexArg(part)
+		} else if strings.Index(part, "agg[") == 0 {
+			mapRed.Aggs = extractAggDefs(part)
 		} else if strings.Index(part, "count") != -1 {
 			mapRed.ShowCount = true
 		}
@@ -1113,8 +1192,10 @@ func extractSort(part string) *SortDef {
 	if len(split) > 1 {
 		if split[1] == "desc" {
 			sortDef.Desc = true
-		} else {
+		} else if split[1] == "asc" {
 			sortDef.Desc = false
+		} else {
+			argError(part, len(split[0])+1, "Invalid sort order '%v', expected asc or desc", split[1])
 		}
 	}
 	return sortDef
@@ -1122,9 +1203,10 @@ func extractSort(part string) *SortDef {
 
 func processTrArguments(command string, csvFmt *CsvFormat) {
 	parts := parseInlineCommand("tr", command)
-	colIndex, err := strconv.Atoi(strings.Replace(parts[1], "c", "", 1))
-	if err != nil {
-		log.Fatalf("Invalid col index for formatting %v", parts[1])
+	colName := strings.Replace(parts[1], "c", "", 1)
+	colIndex, err := strconv.Atoi(colName)
+	if err != nil && !common.IsColumnName(colName) {
+		fatalf("Invalid col index for formatting %v", parts[1])
 	}
 	format := ColumnFormat{}
 	for _, part := range parts[2:] {
@@ -1155,6 +1237,16 @@ func processTrArguments(command string, csvFmt *CsvFormat) {
 			format.Ltrim = extractDelim(part, "ltrim:")
 		}
 	}
+	if err != nil {
+		//the column name is resolved with the headers
+		if csvFmt.ColFmtNames == nil {
+			csvFmt.ColFmtNames = make(map[string][]ColumnFormat)
+		}
+		csvFmt.ColFmtNames[colName] = append(csvFmt.ColFmtNames[colName], format)
+		return
+	}
+	csvFmt.ColRefs = append(csvFmt.ColRefs, colRef{Scope: inputScope, Flag: "tr", Arg: command,
+		Pos: strings.Index(command, parts[1]) + 1, Index: colIndex})
 	fmtMap := csvFmt.ColFmtMap
 	if fmtMap == nil {
 		fmtMap = make(map[int][]ColumnFormat)
@@ -1314,9 +1406,11 @@ func extractCsvIndexArg(arg string) *common.IntRange {
 	start := strings.Index(arg, "[")
 	end := strings.Index(arg, "]")
 	if start >= 0 && end > start {
-		str := string(([]rune(arg))[start+1 : end])
+		str := arg[start+1 : end]
+		validateIndexStr(arg, start+1, str)
 		return common.ParseRange(str)
-	} else {
-		return &common.IntRange{}
+	} else if start >= 0 {
+		argError(arg, len(arg), "Missing ']' in '%v'", arg)
 	}
+	return &common.IntRange{}
 }
@@ -0,0 +1,568 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/abeytom/utilbox/common"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const maxViewColWidth = 40
+
+const viewHelp = "q quit  / filter  s sort  - hide  + show all  y copy cell  Y copy row"
+
+/*
+	processViewOutput opens a full screen viewer of the rows in the terminal. The table output is written instead if
+	the stdout is not a terminal eg. the output is piped, or the writer is set by the pipeline package
+*/
+func processViewOutput(rows []DataRow, csvFmt *CsvFormat, headers []string) {
+	term, err := openTerminal(csvFmt)
+	if err != nil {
+		ProcessTableOutput(rows, csvFmt, headers, csvFmt.out())
+		return
+	}
+	defer term.Close()
+	if err := term.makeRaw(); err != nil {
+		ProcessTableOutput(rows, csvFmt, headers, csvFmt.out())
+		return
+	}
+	defer term.restore()
+	newTableView(rows, headers).run(term)
+}
+
+// tableView is the state of the viewer. The visible are the indices of the rows after the filter and the sort
+type tableView struct {
+	headers  []string
+	cells    [][]string
+	widths   []int
+	types    map[int]string
+	hidden   []bool
+	visible  []int
+	sortCol  int
+	sortDesc bool
+	filter   string
+	editing  bool
+	row      int
+	top      int
+	col      int
+	left     int
+	width    int
+	height   int
+	status   string
+}
+
+func newTableView(rows []DataRow, headers []string) *tableView {
+	colCount := len(headers)
+	for _, row := range rows {
+		if len(row.Cols) > colCount {
+			colCount = len(row.Cols)
+		}
+	}
+	v := &tableView{
+		headers: make([]string, colCount),
+		cells:   make([][]string, len(rows)),
+		widths:  make([]int, colCount),
+		hidden:  make([]bool, colCount),
+		sortCol: -1,
+		width:   80,
+		height:  24,
+	}
+	for i := range v.headers {
+		v.headers[i] = outputHeader(headers, i)
+		// the room for the sort marker
+		v.widths[i] = common.DisplayWidth(v.headers[i]) + 1
+	}
+	for r, row := range rows {
+		v.cells[r] = make([]string, colCount)
+		for i, col := range row.Cols {
+			v.cells[r][i] = strings.Join(cellValues(col), ",")
+			if width := common.DisplayWidth(v.cells[r][i]); width > v.widths[i] {
+				v.widths[i] = width
+			}
+		}
+	}
+	for i, width := range v.widths {
+		if width > maxViewColWidth {
+			v.widths[i] = maxViewColWidth
+		}
+	}
+	v.types = inferColTypes(v.cells)
+	v.refresh()
+	return v
+}
+
+// refresh applies the filter and the sort to the rows
+func (v *tableView) refresh() {
+	v.visible = v.visible[:0]
+	filter := strings.ToLower(v.filter)
+	for r, cells := range v.cells {
+		if filter == "" || v.matches(cells, filter) {
+			v.visible = append(v.visible, r)
+		}
+	}
+	if v.sortCol >= 0 {
+		values := make(map[int]interface{}, len(v.visible))
+		for _, r := range v.visible {
+			values[r] = ConvertTyped(v.cells[r][v.sortCol], v.types[v.sortCol])
+		}
+		sort.SliceStable(v.visible, func(i, j int) bool {
+			cmp := compareValues(values[v.visible[i]], values[v.visible[j]])
+			if v.sortDesc {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	v.row = 0
+	v.top = 0
+}
+
+// matches checks the filter against the cells of the shown columns, case insensitive
+func (v *tableView) matches(cells []string, filter string) bool {
+	for i, cell := range cells {
+		if !v.hidden[i] && strings.Contains(strings.ToLower(cell), filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *tableView) run(term *terminal) {
+	term.write("\x1b[?1049h\x1b[?25l")
+	defer term.write("\x1b[?25h\x1b[?1049l")
+	buf := make([]byte, 256)
+	for {
+		v.height, v.width = term.size()
+		term.write(v.render())
+		n, err := term.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			if !v.handleKey(key, term) {
+				return
+			}
+		}
+	}
+}
+
+// handleKey updates the state for the key. false to quit
+func (v *tableView) handleKey(key string, term *terminal) bool {
+	v.status = ""
+	if v.editing {
+		switch key {
+		case "enter":
+			v.editing = false
+		case "esc":
+			v.editing = false
+			v.filter = ""
+			v.refresh()
+		case "backspace":
+			if runes := []rune(v.filter); len(runes) > 0 {
+				v.filter = string(runes[:len(runes)-1])
+				v.refresh()
+			}
+		default:
+			if len([]rune(key)) == 1 && key >= " " {
+				v.filter += key
+				v.refresh()
+			}
+		}
+		return true
+	}
+	page := v.bodyHeight()
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "up", "k":
+		v.moveRow(-1)
+	case "down", "j", "enter":
+		v.moveRow(1)
+	case "pgup", "b":
+		v.moveRow(-page)
+	case "pgdn", " ", "f":
+		v.moveRow(page)
+	case "home", "g":
+		v.moveRow(-len(v.visible))
+	case "end", "G":
+		v.moveRow(len(v.visible))
+	case "left", "h":
+		v.moveCol(-1)
+	case "right", "l":
+		v.moveCol(1)
+	case "s":
+		if v.sortCol == v.col {
+			v.sortDesc = !v.sortDesc
+		} else {
+			v.sortCol = v.col
+			v.sortDesc = false
+		}
+		v.refresh()
+	case "/":
+		v.editing = true
+	case "esc":
+		if v.filter != "" {
+			v.filter = ""
+			v.refresh()
+		}
+	case "-":
+		v.hideCol()
+	case "+":
+		for i := range v.hidden {
+			v.hidden[i] = false
+		}
+		v.refresh()
+	case "y":
+		if cells := v.selectedCells(); cells != nil {
+			v.copy(term, cells[v.col], "the cell")
+		}
+	case "Y":
+		if cells := v.selectedCells(); cells != nil {
+			var shown []string
+			for i, cell := range cells {
+				if !v.hidden[i] {
+					shown = append(shown, cell)
+				}
+			}
+			v.copy(term, csvLine(shown), "the row")
+		}
+	case "?":
+		v.status = viewHelp
+	}
+	return true
+}
+
+func (v *tableView) moveRow(delta int) {
+	v.row += delta
+	if v.row >= len(v.visible) {
+		v.row = len(v.visible) - 1
+	}
+	if v.row < 0 {
+		v.row = 0
+	}
+}
+
+// moveCol selects the next shown column in the direction
+func (v *tableView) moveCol(delta int) {
+	for i := v.col + delta; i >= 0 && i < len(v.headers); i += delta {
+		if !v.hidden[i] {
+			v.col = i
+			return
+		}
+	}
+}
+
+// hideCol hides the selected column, the last shown column is not hidden
+func (v *tableView) hideCol() {
+	shown := 0
+	for _, hidden := range v.hidden {
+		if !hidden {
+			shown++
+		}
+	}
+	if shown <= 1 {
+		v.status = "The last column cannot be hidden"
+		return
+	}
+	v.hidden[v.col] = true
+	prev := v.col
+	v.moveCol(1)
+	if v.col == prev {
+		v.moveCol(-1)
+	}
+	if v.filter != "" {
+		v.refresh()
+	}
+}
+
+func (v *tableView) selectedCells() []string {
+	if len(v.visible) == 0 || len(v.headers) == 0 {
+		return nil
+	}
+	return v.cells[v.visible[v.row]]
+}
+
+func (v *tableView) copy(term *terminal, text string, what string) {
+	if err := copyToClipboard(term, text); err != nil {
+		v.status = "Failed to copy " + what + ": " + err.Error()
+	} else {
+		v.status = "Copied " + what
+	}
+}
+
+// bodyHeight is the number of rows between the header and the status line
+func (v *tableView) bodyHeight() int {
+	if v.height < 3 {
+		return 1
+	}
+	return v.height - 2
+}
+
+// shownCols returns the widths of the columns that fit in the view. The selected column is scrolled into the view
+func (v *tableView) shownCols() ([]int, map[int]int) {
+	if v.left > v.col {
+		v.left = v.col
+	}
+	for v.left < v.col && v.colsWidth(v.left, v.col) > v.width {
+		v.left++
+	}
+	var cols []int
+	widths := make(map[int]int)
+	used := 0
+	for i := v.left; i < len(v.headers); i++ {
+		if v.hidden[i] {
+			continue
+		}
+		// the last column is clipped, the lines do not wrap
+		width := v.widths[i]
+		if used+width+2 > v.width {
+			width = v.width - used - 2
+		}
+		if width < 1 {
+			break
+		}
+		cols = append(cols, i)
+		widths[i] = width
+		used += width + 2
+	}
+	return cols, widths
+}
+
+// colsWidth is the width of the shown columns from the start to the end, inclusive
+func (v *tableView) colsWidth(start int, end int) int {
+	width := 0
+	for i := start; i <= end; i++ {
+		if !v.hidden[i] {
+			width += v.widths[i] + 2
+		}
+	}
+	return width - 2
+}
+
+func (v *tableView) render() string {
+	body := v.bodyHeight()
+	if v.row < v.top {
+		v.top = v.row
+	}
+	if v.row >= v.top+body {
+		v.top = v.row - body + 1
+	}
+	cols, widths := v.shownCols()
+	var sb strings.Builder
+	sb.WriteString("\x1b[H")
+
+	var header strings.Builder
+	for _, i := range cols {
+		name := v.headers[i]
+		if i == v.sortCol {
+			if v.sortDesc {
+				name += "↓"
+			} else {
+				name += "↑"
+			}
+		}
+		header.WriteString(v.cell(name, widths[i], i == v.col, "\x1b[4m", "\x1b[24m"))
+	}
+	sb.WriteString("\x1b[1m" + header.String() + "\x1b[0m\x1b[K\r\n")
+
+	for line := 0; line < body; line++ {
+		index := v.top + line
+		if index < len(v.visible) {
+			cells := v.cells[v.visible[index]]
+			var row strings.Builder
+			for _, i := range cols {
+				row.WriteString(v.cell(cells[i], widths[i], index == v.row && i == v.col, "\x1b[1m", "\x1b[22m"))
+			}
+			if index == v.row {
+				sb.WriteString("\x1b[7m" + row.String() + "\x1b[27m")
+			} else {
+				sb.WriteString(row.String())
+			}
+		}
+		sb.WriteString("\x1b[K\r\n")
+	}
+
+	status := v.status
+	if v.editing {
+		status = "/" + v.filter
+	} else if status == "" {
+		status = fmt.Sprintf("row %v/%v", v.row+1, len(v.visible))
+		if len(v.visible) == 0 {
+			status = "no rows"
+		}
+		if len(v.visible) != len(v.cells) {
+			status += fmt.Sprintf(" of %v", len(v.cells))
+		}
+		if v.filter != "" {
+			status += "  filter: " + v.filter
+		}
+		status += "  ? help"
+	}
+	sb.WriteString("\x1b[7m" + fitCell(status, v.width) + "\x1b[27m\x1b[K")
+	return sb.String()
+}
+
+// cell pads or truncates the value to the width. The selected cell is wrapped by the on and off codes
+func (v *tableView) cell(value string, width int, selected bool, on string, off string) string {
+	text := fitCell(value, width)
+	if selected {
+		return on + text + off + "  "
+	}
+	return text + "  "
+}
+
+// fitCell pads the str with the spaces, or truncates it with an ellipsis if it is wider than the width
+func fitCell(str string, width int) string {
+	return common.PadWidth(common.TruncateWidth(strings.ReplaceAll(str, "\n", " "), width), width, false)
+}
+
+func csvLine(values []string) string {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(values)
+	writer.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// parseKeys splits the input into the keys. The escape sequences of the arrows and the pages are named
+func parseKeys(input []byte) []string {
+	var keys []string
+	str := string(input)
+	for len(str) > 0 {
+		if strings.HasPrefix(str, "\x1b[") || strings.HasPrefix(str, "\x1bO") {
+			end := 2
+			for end < len(str) && (str[end] < 0x40 || str[end] > 0x7e) {
+				end++
+			}
+			if end == len(str) {
+				keys = append(keys, "esc")
+				break
+			}
+			keys = append(keys, escapeKeys[str[2:end+1]])
+			str = str[end+1:]
+			continue
+		}
+		switch str[0] {
+		case 0x1b:
+			keys = append(keys, "esc")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		default:
+			r := []rune(str)[0]
+			keys = append(keys, string(r))
+			str = str[len(string(r)):]
+			continue
+		}
+		str = str[1:]
+	}
+	return keys
+}
+
+var escapeKeys = map[string]string{
+	"A":  "up",
+	"B":  "down",
+	"C":  "right",
+	"D":  "left",
+	"H":  "home",
+	"F":  "end",
+	"1~": "home",
+	"4~": "end",
+	"5~": "pgup",
+	"6~": "pgdn",
+}
+
+// terminal is the controlling terminal of the process. The raw mode is set with stty
+type terminal struct {
+	file  *os.File
+	state string
+}
+
+func openTerminal(csvFmt *CsvFormat) (*terminal, error) {
+	if csvFmt.Writer != nil {
+		return nil, errors.New("the output is not the stdout")
+	}
+	stat, err := os.Stdout.Stat()
+	if err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return nil, errors.New("the stdout is not a terminal")
+	}
+	file, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &terminal{file: file}, nil
+}
+
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.file
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (t *terminal) makeRaw() error {
+	state, err := t.stty("-g")
+	if err != nil {
+		return err
+	}
+	t.state = state
+	_, err = t.stty("raw", "-echo")
+	return err
+}
+
+func (t *terminal) restore() {
+	if t.state != "" {
+		t.stty(t.state)
+	}
+}
+
+// size returns the rows and the columns of the terminal, 24x80 if unknown
+func (t *terminal) size() (int, int) {
+	out, err := t.stty("size")
+	if err == nil {
+		parts := strings.Fields(out)
+		if len(parts) == 2 {
+			rows, err1 := strconv.Atoi(parts[0])
+			cols, err2 := strconv.Atoi(parts[1])
+			if err1 == nil && err2 == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+func (t *terminal) write(str string) {
+	os.Stdout.WriteString(str)
+}
+
+func (t *terminal) Close() error {
+	return t.file.Close()
+}
+
+/*
+	copyToClipboard copies the text with pbcopy, wl-copy, xclip or xsel, whichever is installed. The OSC 52 escape
+	sequence is used otherwise, it is supported by most of the terminals and over ssh
+*/
+func copyToClipboard(term *terminal, text string) error {
+	for _, args := range [][]string{{"pbcopy"}, {"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}} {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	term.write("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07")
+	return nil
+}
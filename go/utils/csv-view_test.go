@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestView() *tableView {
+	rows := []DataRow{
+		{Cols: []interface{}{"api-1", "512Mi", "Running"}},
+		{Cols: []interface{}{"db-1", "8Gi", "Running"}},
+		{Cols: []interface{}{"api-2", "2Gi", "Pending"}},
+	}
+	return newTableView(rows, []string{"NAME", "SIZE", "STATUS"})
+}
+
+func sendKeys(t *testing.T, v *tableView, keys ...string) {
+	for _, key := range keys {
+		if !v.handleKey(key, nil) {
+			t.Fatalf("Unexpected quit for the key %v", key)
+		}
+	}
+}
+
+func assertVisible(t *testing.T, v *tableView, expected ...int) {
+	if !reflect.DeepEqual(append([]int{}, v.visible...), append([]int{}, expected...)) {
+		t.Fatalf("Visible Mismatch Actual=%v, Expected: %v", v.visible, expected)
+	}
+}
+
+func TestViewParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("\x1b[A\x1b[6~j/\x7f\x1bq\r\x03é"))
+	expected := []string{"up", "pgdn", "j", "/", "backspace", "esc", "q", "enter", "ctrl-c", "é"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Keys Mismatch Actual=%v, Expected: %v", keys, expected)
+	}
+}
+
+func TestViewSort(t *testing.T) {
+	v := newTestView()
+	assertVisible(t, v, 0, 1, 2)
+
+	// the sizes are sorted by the value, not as the strings
+	sendKeys(t, v, "l", "s")
+	if v.sortCol != 1 || v.sortDesc {
+		t.Fatalf("Sort Mismatch Actual=%v,%v, Expected: 1,false", v.sortCol, v.sortDesc)
+	}
+	assertVisible(t, v, 0, 2, 1)
+	sendKeys(t, v, "s")
+	if v.sortCol != 1 || !v.sortDesc {
+		t.Fatalf("Sort Mismatch Actual=%v,%v, Expected: 1,true", v.sortCol, v.sortDesc)
+	}
+	assertVisible(t, v, 1, 2, 0)
+
+	sendKeys(t, v, "h", "s")
+	if v.sortCol != 0 || v.sortDesc {
+		t.Fatalf("Sort Mismatch Actual=%v,%v, Expected: 0,false", v.sortCol, v.sortDesc)
+	}
+	assertVisible(t, v, 0, 2, 1)
+	if v.handleKey("q", nil) {
+		t.Fatalf("Expected q to quit")
+	}
+}
+
+func TestViewFilter(t *testing.T) {
+	v := newTestView()
+	sendKeys(t, v, "/", "a", "p", "i", "-", "2")
+	if !v.editing || v.filter != "api-2" {
+		t.Fatalf("Filter Mismatch Actual=%v,%v, Expected: true,api-2", v.editing, v.filter)
+	}
+	assertVisible(t, v, 2)
+	sendKeys(t, v, "backspace", "backspace")
+	assertVisible(t, v, 0, 2)
+
+	// the filter is kept after the enter, the esc clears it
+	sendKeys(t, v, "enter")
+	if v.editing || v.filter != "api" {
+		t.Fatalf("Filter Mismatch Actual=%v,%v, Expected: false,api", v.editing, v.filter)
+	}
+	assertVisible(t, v, 0, 2)
+	sendKeys(t, v, "esc")
+	assertVisible(t, v, 0, 1, 2)
+
+	sendKeys(t, v, "/", "P", "e", "n", "esc")
+	if v.editing || v.filter != "" {
+		t.Fatalf("Filter Mismatch Actual=%v,%v, Expected: false,", v.editing, v.filter)
+	}
+	assertVisible(t, v, 0, 1, 2)
+
+	// the hidden columns are not matched
+	sendKeys(t, v, "/", "r", "u", "n")
+	assertVisible(t, v, 0, 1)
+	sendKeys(t, v, "enter", "l", "l", "-")
+	assertVisible(t, v)
+}
+
+func TestViewHideCols(t *testing.T) {
+	v := newTestView()
+	sendKeys(t, v, "l", "-")
+	if !reflect.DeepEqual(v.hidden, []bool{false, true, false}) || v.col != 2 {
+		t.Fatalf("Hidden Mismatch Actual=%v,%v, Expected: [false true false],2", v.hidden, v.col)
+	}
+	sendKeys(t, v, "-")
+	if !reflect.DeepEqual(v.hidden, []bool{false, true, true}) || v.col != 0 {
+		t.Fatalf("Hidden Mismatch Actual=%v,%v, Expected: [false true true],0", v.hidden, v.col)
+	}
+	sendKeys(t, v, "-")
+	if v.hidden[0] || v.status != "The last column cannot be hidden" {
+		t.Fatalf("Expected the last column to be shown, the status is %v", v.status)
+	}
+	sendKeys(t, v, "+")
+	if !reflect.DeepEqual(v.hidden, []bool{false, false, false}) {
+		t.Fatalf("Hidden Mismatch Actual=%v, Expected: [false false false]", v.hidden)
+	}
+}
+
+func TestViewShownCols(t *testing.T) {
+	v := newTestView()
+	v.width = 12
+	cols, widths := v.shownCols()
+	if !reflect.DeepEqual(cols, []int{0, 1}) || !reflect.DeepEqual(widths, map[int]int{0: 5, 1: 3}) {
+		t.Fatalf("Cols Mismatch Actual=%v,%v, Expected: [0 1],map[0:5 1:3]", cols, widths)
+	}
+
+	// the selected column is scrolled into the view
+	sendKeys(t, v, "l", "l")
+	cols, widths = v.shownCols()
+	if v.left != 2 || !reflect.DeepEqual(cols, []int{2}) || !reflect.DeepEqual(widths, map[int]int{2: 7}) {
+		t.Fatalf("Cols Mismatch Actual=%v,%v,%v, Expected: 2,[2],map[2:7]", v.left, cols, widths)
+	}
+	sendKeys(t, v, "h")
+	cols, _ = v.shownCols()
+	if v.left != 1 || !reflect.DeepEqual(cols, []int{1, 2}) {
+		t.Fatalf("Cols Mismatch Actual=%v,%v, Expected: 1,[1 2]", v.left, cols)
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"github.com/abeytom/utilbox/common"
+	"strings"
+)
+
+type WindowDef struct {
+	Funcs    []WindowFunc
+	PartCols *common.IntRange
+}
+
+type WindowFunc struct {
+	Name    string
+	Cols    *common.IntRange
+	indices []int
+}
+
+// the state of a partition, the rows of a partition need not be adjacent
+type windowState struct {
+	rowNumber int64
+	rank      int64
+	rankKey   string
+	sums      map[int]*sumAgg
+	prev      map[int]interface{}
+}
+
+/*
+	win..row_number..sum[4]..delta[2,3]
+	win..part[0]..rank[LAG]..prev[2]     => the functions are computed per partition
+	win..rank                            => the rank based on the sort cols
+*/
+func extractWindowDef(arg string) *WindowDef {
+	parts := parseInlineCommand("win", arg)
+	if len(parts) < 2 {
+		fatalf("Invalid window '%v'. The format is win..<func>[<cols>]..part[<cols>]", arg)
+	}
+	def := &WindowDef{}
+	for _, part := range parts[1:] {
+		name := part
+		var cols *common.IntRange
+		if index := strings.Index(part, "["); index != -1 {
+			name = part[:index]
+			cols = extractCsvIndexArg(part[index:])
+		}
+		switch name {
+		case "part":
+			def.PartCols = cols
+		case "row_number", "rank":
+			def.Funcs = append(def.Funcs, WindowFunc{Name: name, Cols: cols})
+		case "sum", "prev", "delta":
+			if cols == nil {
+				fatalf("Invalid window function '%v', the columns are required eg. %v[2]", part, name)
+			}
+			def.Funcs = append(def.Funcs, WindowFunc{Name: name, Cols: cols})
+		default:
+			fatalf("Unknown window function '%v'", part)
+		}
+	}
+	return def
+}
+
+// applyWindow appends the window columns to the rows. The rows are processed in the sorted order
+func applyWindow(csvFmt *CsvFormat, rows []DataRow, headers []string) ([]DataRow, []string) {
+	def := csvFmt.WindowDef
+	if len(rows) == 0 {
+		return rows, headers
+	}
+	var partIndices []int
+	if def.PartCols != nil {
+		resolveRange(def.PartCols, headers, "win part")
+		partIndices = rangeIndices(def.PartCols, rows[0])
+	}
+	nHeaders := append([]string{}, headers...)
+	for i := range def.Funcs {
+		fn := &def.Funcs[i]
+		if fn.Cols == nil && fn.Name == "rank" {
+			if csvFmt.SortDef == nil {
+				fatalf("The window function rank needs either the columns eg. rank[2] or the sort")
+			}
+			fn.Cols = csvFmt.SortDef.SortCols
+		}
+		if fn.Cols != nil {
+			resolveRange(fn.Cols, headers, "win "+fn.Name)
+			fn.indices = rangeIndices(fn.Cols, rows[0])
+		}
+		nHeaders = append(nHeaders, windowHeaders(fn, headers)...)
+	}
+	convertWindowCols(csvFmt, rows)
+
+	states := make(map[string]*windowState)
+	for r := range rows {
+		row := &rows[r]
+		var keys []string
+		for _, index := range partIndices {
+			keys = append(keys, cellString(pivotCol(*row, index)))
+		}
+		partKey := strings.Join(keys, "\x00")
+		state, exists := states[partKey]
+		if !exists {
+			state = &windowState{sums: make(map[int]*sumAgg), prev: make(map[int]interface{})}
+			states[partKey] = state
+		}
+		state.rowNumber++
+		var cols []interface{}
+		for _, fn := range def.Funcs {
+			cols = append(cols, state.apply(fn, *row)...)
+		}
+		for _, fn := range def.Funcs {
+			for _, col := range fn.indices {
+				state.prev[col] = pivotCol(*row, col)
+			}
+		}
+		row.Cols = append(row.Cols, cols...)
+	}
+	return rows, nHeaders
+}
+
+// the values of the quantity columns are converted by the type of the column, the quantities are displayed as is
+func convertWindowCols(csvFmt *CsvFormat, rows []DataRow) {
+	for _, fn := range csvFmt.WindowDef.Funcs {
+		if fn.Name == "row_number" || fn.Name == "rank" {
+			continue
+		}
+		for _, index := range fn.indices {
+			for _, row := range rows {
+				if index < 0 || index >= len(row.Cols) {
+					continue
+				}
+				if qty, ok := convertCol(csvFmt, index, row.Cols[index]).(Quantity); ok {
+					row.Cols[index] = qty
+				}
+			}
+		}
+	}
+}
+
+func (s *windowState) apply(fn WindowFunc, row DataRow) []interface{} {
+	switch fn.Name {
+	case "row_number":
+		return []interface{}{s.rowNumber}
+	case "rank":
+		var keys []string
+		for _, index := range fn.indices {
+			keys = append(keys, cellString(pivotCol(row, index)))
+		}
+		rankKey := strings.Join(keys, "\x00")
+		if s.rowNumber == 1 || rankKey != s.rankKey {
+			s.rank = s.rowNumber
+			s.rankKey = rankKey
+		}
+		return []interface{}{s.rank}
+	}
+	var cols []interface{}
+	for _, index := range fn.indices {
+		col := pivotCol(row, index)
+		prev, hasPrev := s.prev[index]
+		switch fn.Name {
+		case "sum":
+			sum, exists := s.sums[index]
+			if !exists {
+				sum = &sumAgg{typed: typed{TypeNumber}}
+				if qty, ok := col.(Quantity); ok {
+					sum.typ = qty.Type
+				}
+				s.sums[index] = sum
+			}
+			sum.Add(cellString(col))
+			cols = append(cols, sum.Result())
+		case "prev":
+			if hasPrev {
+				cols = append(cols, prev)
+			} else {
+				cols = append(cols, "")
+			}
+		case "delta":
+			if hasPrev {
+				cols = append(cols, numericDelta(prev, col))
+			} else {
+				cols = append(cols, "")
+			}
+		}
+	}
+	return cols
+}
+
+// the delta is empty if either of the values is not a number. The delta of the quantities is a quantity
+func numericDelta(prev interface{}, curr interface{}) interface{} {
+	prevVal := ConvertIfNeeded(prev)
+	currVal := ConvertIfNeeded(curr)
+	prevQty, prevIsQty := prevVal.(Quantity)
+	currQty, currIsQty := currVal.(Quantity)
+	if prevIsQty && currIsQty && prevQty.Type == currQty.Type {
+		return currQty.delta(prevQty)
+	}
+	prevInt, prevIsInt := prevVal.(int64)
+	currInt, currIsInt := currVal.(int64)
+	if prevIsInt && currIsInt {
+		return currInt - prevInt
+	}
+	prevNum, prevOk := toFloat64(prevVal)
+	currNum, currOk := toFloat64(currVal)
+	if prevOk && currOk {
+		return currNum - prevNum
+	}
+	return ""
+}
+
+func windowHeaders(fn *WindowFunc, headers []string) []string {
+	if fn.Name == "row_number" || fn.Name == "rank" {
+		return []string{fn.Name}
+	}
+	var nHeaders []string
+	for _, index := range fn.indices {
+		nHeaders = append(nHeaders, fn.Name+"("+outputHeader(headers, index)+")")
+	}
+	return nHeaders
+}
+
+func rangeIndices(r *common.IntRange, row DataRow) []int {
+	return common.GetFilterItemIndices(common.IApplyRange(row.Cols, r))
+}
@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Knetic/govaluate"
+	"github.com/abeytom/utilbox/common"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	The functions available in the calc, filter and having expressions
+
+	upper([0]) lower([0]) len([0])
+	substr([0],2) substr([0],2,5)
+	replace([0],"-","_")
+	regex_extract([0],"v(\d+)")      => the first group, or the whole match if there is no group
+	regex_extract([0],"(\w+)-(\d+)",2)
+	if([4]>0,"lag","ok")
+	coalesce([5],[6],"none")         => the first non-empty value
+	duration([4])                    => seconds. 5d3h, 1h30m, 500ms
+	bytes([3])                       => bytes. 512Mi, 1.5G, 100KB
+	parse_time([2])                  => unix seconds
+	now()                            => unix seconds
+	age([2])                         => seconds since the time. a duration eg. the kubectl AGE or a number is used as is
+*/
+var exprFunctions = map[string]govaluate.ExpressionFunction{
+	"upper": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("upper", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToUpper(argString(args[0])), nil
+	},
+	"lower": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("lower", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return strings.ToLower(argString(args[0])), nil
+	},
+	"len": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("len", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return float64(len([]rune(argString(args[0])))), nil
+	},
+	"substr":        exprSubstr,
+	"replace":       exprReplace,
+	"regex_extract": exprRegexExtract,
+	"if": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("if", args, 3, 3); err != nil {
+			return nil, err
+		}
+		cond, ok := args[0].(bool)
+		if !ok {
+			return nil, fmt.Errorf("if() expects a condition, found '%v'", args[0])
+		}
+		if cond {
+			return args[1], nil
+		}
+		return args[2], nil
+	},
+	"coalesce": func(args ...interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil && argString(arg) != "" {
+				return arg, nil
+			}
+		}
+		return "", nil
+	},
+	"duration": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("duration", args, 1, 1); err != nil {
+			return nil, err
+		}
+		return argDuration(args[0])
+	},
+	"bytes": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("bytes", args, 1, 1); err != nil {
+			return nil, err
+		}
+		if num, ok := args[0].(float64); ok {
+			return num, nil
+		}
+		return common.ParseBytes(argString(args[0]))
+	},
+	"parse_time": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("parse_time", args, 1, 1); err != nil {
+			return nil, err
+		}
+		t, err := common.ParseTime(argString(args[0]))
+		if err != nil {
+			return nil, err
+		}
+		return unixSeconds(t), nil
+	},
+	"now": func(args ...interface{}) (interface{}, error) {
+		return unixSeconds(time.Now()), nil
+	},
+	"age": func(args ...interface{}) (interface{}, error) {
+		if err := checkArgCount("age", args, 1, 1); err != nil {
+			return nil, err
+		}
+		if num, ok := args[0].(float64); ok {
+			return num, nil
+		}
+		str := argString(args[0])
+		if common.IsDuration(str) {
+			return argDuration(str)
+		}
+		t, err := common.ParseTime(str)
+		if err != nil {
+			return nil, err
+		}
+		return time.Since(t).Seconds(), nil
+	},
+}
+
+// the duration and size literals compared with the functions eg. age([5]) > "2d" are converted into numbers
+var exprLiteralRegex = regexp.MustCompile(`((?:age|duration|bytes)\([^()]*\)\s*(?:[<>]=?|==|!=)\s*)"([^"]+)"`)
+
+var exprLiteralRevRegex = regexp.MustCompile(`"([^"]+)"(\s*(?:[<>]=?|==|!=)\s*(?:age|duration|bytes)\()`)
+
+func newEvaluableExpression(exprStr string) (*govaluate.EvaluableExpression, error) {
+	return govaluate.NewEvaluableExpressionWithFunctions(rewriteExprLiterals(exprStr), exprFunctions)
+}
+
+func rewriteExprLiterals(exprStr string) string {
+	exprStr = exprLiteralRegex.ReplaceAllStringFunc(exprStr, func(match string) string {
+		parts := exprLiteralRegex.FindStringSubmatch(match)
+		return parts[1] + literalFunc(parts[1], parts[2])
+	})
+	return exprLiteralRevRegex.ReplaceAllStringFunc(exprStr, func(match string) string {
+		parts := exprLiteralRevRegex.FindStringSubmatch(match)
+		return literalFunc(parts[2], parts[1]) + parts[2]
+	})
+}
+
+func literalFunc(fnExpr string, literal string) string {
+	if strings.Contains(fnExpr, "bytes(") {
+		return fmt.Sprintf(`bytes("%v")`, literal)
+	}
+	return fmt.Sprintf(`duration("%v")`, literal)
+}
+
+func exprSubstr(args ...interface{}) (interface{}, error) {
+	if err := checkArgCount("substr", args, 2, 3); err != nil {
+		return nil, err
+	}
+	runes := []rune(argString(args[0]))
+	start, ok := args[1].(float64)
+	if !ok {
+		return nil, fmt.Errorf("substr() expects a numeric start, found '%v'", args[1])
+	}
+	from := int(start)
+	if from < 0 {
+		from = len(runes) + from
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from > len(runes) {
+		from = len(runes)
+	}
+	to := len(runes)
+	if len(args) == 3 {
+		length, ok := args[2].(float64)
+		if !ok {
+			return nil, fmt.Errorf("substr() expects a numeric length, found '%v'", args[2])
+		}
+		if from+int(length) < to {
+			to = from + int(length)
+		}
+	}
+	if to < from {
+		return "", nil
+	}
+	return string(runes[from:to]), nil
+}
+
+func exprReplace(args ...interface{}) (interface{}, error) {
+	if err := checkArgCount("replace", args, 3, 3); err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(argString(args[0]), argString(args[1]), argString(args[2])), nil
+}
+
+func exprRegexExtract(args ...interface{}) (interface{}, error) {
+	if err := checkArgCount("regex_extract", args, 2, 3); err != nil {
+		return nil, err
+	}
+	re, err := compileRegex(argString(args[1]))
+	if err != nil {
+		return nil, err
+	}
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	if len(args) == 3 {
+		num, ok := args[2].(float64)
+		if !ok {
+			return nil, fmt.Errorf("regex_extract() expects a numeric group, found '%v'", args[2])
+		}
+		group = int(num)
+	}
+	if group < 0 || group > re.NumSubexp() {
+		return nil, fmt.Errorf("regex_extract() invalid group %v for '%v'", group, re)
+	}
+	matches := re.FindStringSubmatch(argString(args[0]))
+	if matches == nil {
+		return "", nil
+	}
+	return matches[group], nil
+}
+
+// the cache is shared by the pipelines which can run on the different goroutines
+var regexCache = make(map[string]*regexp.Regexp)
+var regexCacheLock sync.Mutex
+
+// the expressions are evaluated per row, so the patterns are compiled once
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	regexCacheLock.Lock()
+	defer regexCacheLock.Unlock()
+	if re, exists := regexCache[pattern]; exists {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache[pattern] = re
+	return re, nil
+}
+
+func checkArgCount(name string, args []interface{}, min int, max int) error {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return fmt.Errorf("%v() expects %v args, found %v", name, min, len(args))
+		}
+		return fmt.Errorf("%v() expects %v to %v args, found %v", name, min, max, len(args))
+	}
+	return nil
+}
+
+func argString(arg interface{}) string {
+	switch arg.(type) {
+	case nil:
+		return ""
+	case string:
+		return arg.(string)
+	default:
+		return common.ToString(arg)
+	}
+}
+
+// the numbers are considered as seconds
+func argDuration(arg interface{}) (interface{}, error) {
+	if num, ok := arg.(float64); ok {
+		return num, nil
+	}
+	str := argString(arg)
+	if !common.IsDuration(str) {
+		return nil, errors.New("invalid duration '" + str + "'")
+	}
+	return common.ParseDuration(str).Seconds(), nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/Knetic/govaluate"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"reflect"
@@ -98,6 +100,14 @@ func readStdIn2(lineCb func(line []byte)) {
 }
 
 func JsonParseLine(args []string) {
+	parseLineRecords(args, parseJsonBytes)
+}
+
+/*
+	parseLineRecords applies the flags on each line of the stdin. The parse returns the records of the line, nil if
+	the line is not a record eg. the json or the logfmt. The lines that are not records are printed as is
+*/
+func parseLineRecords(args []string, parse func(line []byte) []map[string]interface{}) {
 	var wExpr *ExprWrap
 	csvFmt := &CsvFormat{
 		ColExt:      &common.IntRange{},
@@ -109,6 +119,7 @@ func JsonParseLine(args []string) {
 		IsLMerge:    false,
 		NoHeaderOut: true,
 	}
+	defer exitOnError()
 	doParseCsvArgs(args, csvFmt)
 	hasFilter := csvFmt.Filter != nil && csvFmt.Filter.Expr != nil
 	if hasFilter {
@@ -118,15 +129,15 @@ func JsonParseLine(args []string) {
 		if !hasFilter {
 			log.Fatal(errors.New("No keys"))
 		}
-		applyFilter(csvFmt)
+		applyFilter(csvFmt, parse)
 		return
 	}
 	printKeys := len(csvFmt.KeyDef.Fields) == 0
 	keyMap := make(map[string]bool)
 
 	var cb = func(line []byte) {
-		array := parseJsonBytes(line)
-		//print the line if it is not a json
+		array := parse(line)
+		//print the line if it is not a record
 		if array == nil {
 			if !printKeys {
 				fmt.Println(string(line))
@@ -231,15 +242,15 @@ func getValueForKeyFromMap(json map[string]interface{}, segments []string) inter
 	return ""
 }
 
-func applyFilter(csvFmt *CsvFormat) {
+func applyFilter(csvFmt *CsvFormat, parse func(line []byte) []map[string]interface{}) {
 	expr := csvFmt.Filter.Expr
 	wExpr := NewExprWrap(expr)
 	if len(wExpr.keys) == 0 {
-		log.Fatalf("Invalid Expr '%v'. Atleast one variable is expected", csvFmt.Filter.ExprStr)
+		fatalf("Invalid Expr '%v'. Atleast one variable is expected", csvFmt.Filter.ExprStr)
 	}
 	var cb = func(line []byte) {
-		array := parseJsonBytes(line)
-		if applyFilter2(wExpr, array) {
+		array := parse(line)
+		if array != nil && applyFilter2(wExpr, array) {
 			fmt.Println(string(line))
 		}
 	}
@@ -255,6 +266,32 @@ func JsonParse(args []string) {
 	if array == nil {
 		log.Fatalf("Unsupported JSON %s", string(jsonBytes))
 	}
+	defer exitOnError()
+	processJsonArray(parseJsonArgs(args), array)
+}
+
+// ParseJsonArgs parses the flags of the jp and yp commands, the default output is the table
+func ParseJsonArgs(args []string) (csvFmt *CsvFormat, err error) {
+	defer recoverError(&err)
+	return parseJsonArgs(args), nil
+}
+
+// ProcessJson reads the json array or object from the reader and writes the output to the Writer of the format
+func ProcessJson(csvFmt *CsvFormat, reader io.Reader) (err error) {
+	defer recoverError(&err)
+	jsonBytes, readErr := ioutil.ReadAll(reader)
+	if readErr != nil {
+		return readErr
+	}
+	array := parseJsonBytes(jsonBytes)
+	if array == nil {
+		return &CsvError{Message: "Unsupported JSON, expected an array or an object"}
+	}
+	processJsonArray(csvFmt, array)
+	return nil
+}
+
+func parseJsonArgs(args []string) *CsvFormat {
 	csvFmt := &CsvFormat{
 		ColExt:    &common.IntRange{},
 		RowExt:    &common.IntRange{},
@@ -265,28 +302,48 @@ func JsonParse(args []string) {
 		OutputDef: &OutputDef{Type: "table"},
 		IsLMerge:  false,
 	}
-	doParseCsvArgs(args, csvFmt)
-	if csvFmt.KeyDef != nil {
-		if len(csvFmt.KeyDef.Fields) == 0 {
-			keys := JsonKeys(array)
-			for _, key := range keys {
-				if strings.Index(key.Key, "\\.") != -1 {
-					fmt.Printf("'%v'\n", key.Key)
-				} else {
-					fmt.Printf("%v\n", key.Key)
-				}
+	return doParseCsvArgs(args, csvFmt)
+}
+
+func processJsonArray(csvFmt *CsvFormat, array []map[string]interface{}) {
+	if csvFmt.KeyDef == nil {
+		return
+	}
+	if len(csvFmt.KeyDef.Fields) == 0 {
+		keys := JsonKeys(array)
+		for _, key := range keys {
+			if strings.Index(key.Key, "\\.") != -1 {
+				fmt.Fprintf(csvFmt.out(), "'%v'\n", key.Key)
+			} else {
+				fmt.Fprintf(csvFmt.out(), "%v\n", key.Key)
 			}
-		} else {
-			keys := csvFmt.KeyDef.Fields
-			rows := Flatten(array, keys)
-			processOutput(csvFmt, &DataRows{
-				DataRows:     rows,
-				Headers:      keys,
-				GroupByCount: 0,
-				Converted:    false,
-			})
 		}
+	} else {
+		keys := csvFmt.KeyDef.Fields
+		processFlatRows(csvFmt, keys, Flatten(array, keys))
+	}
+}
+
+// processFlatRows writes the flattened rows of the json or the yaml, the stats of the columns if the stats is set
+func processFlatRows(csvFmt *CsvFormat, keys []string, rows []DataRow) {
+	if csvFmt.Stats {
+		types := inferRowTypes(rows)
+		for index, typ := range resolveTypeDefs(csvFmt.TypeDefs, keys) {
+			types[index] = typ
+		}
+		profiler := NewColumnProfiler(keys, types)
+		for _, row := range rows {
+			profiler.AddCols(row.Cols)
+		}
+		processOutput(csvFmt, profiler.ToDataRows())
+		return
 	}
+	processOutput(csvFmt, &DataRows{
+		DataRows:     rows,
+		Headers:      keys,
+		GroupByCount: 0,
+		Converted:    false,
+	})
 }
 
 func parseJsonBytes(jsonBytes []byte) []map[string]interface{} {
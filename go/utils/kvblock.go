@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// KvBlockDef is the format of the key value blocks. A record starts at the line matching the Start, or after the
+// blank lines if there is no Start
+type KvBlockDef struct {
+	Start *regexp.Regexp
+	Sep   string
+}
+
+// KvBlocks are the records of the key value blocks. The Keys are the union of the keys in the order of appearance
+type KvBlocks struct {
+	Keys    []string
+	Records []map[string]string
+}
+
+/*
+	split:kvblock
+	split:kvblock..start:^Name:   => a record starts at the line matching the regex
+	split:kvblock..sep:=          => the separator of the key and the value. Default is ':'
+*/
+func extractKvBlockDef(arg string) *KvBlockDef {
+	def := &KvBlockDef{Sep: ":"}
+	for _, part := range strings.Split(strings.TrimPrefix(arg, "split:kvblock"), "..") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "start:") {
+			regex, err := regexp.Compile(part[6:])
+			if err != nil {
+				fatalf("Invalid start regex '%v' in '%v'. The error is %v", part[6:], arg, err)
+			}
+			def.Start = regex
+		} else if strings.HasPrefix(part, "sep:") {
+			def.Sep = part[4:]
+			if def.Sep != ":" {
+				def.Sep = extractDelim(def.Sep, "")
+			}
+			if def.Sep == "" {
+				fatalf("The sep of '%v' is empty", arg)
+			}
+		} else {
+			fatalf("Invalid option '%v' in '%v'. The options are start and sep eg. split:kvblock..start:^Name:..sep:=",
+				part, arg)
+		}
+	}
+	return def
+}
+
+/*
+	parseKvBlocks reads the "Key: Value" lines of the describe style outputs eg. kubectl describe, systemctl show.
+	The indented lines without the separator are the continuation of the previous value, the values of a repeated key
+	are merged with comma. The lines before the first Start are skipped
+*/
+func parseKvBlocks(reader io.Reader, def *KvBlockDef) KvBlocks {
+	var blocks KvBlocks
+	seen := make(map[string]bool)
+	var record map[string]string
+	lastKey := ""
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if (def.Start != nil && def.Start.MatchString(line)) || (def.Start == nil && record == nil && line != "") {
+			record = make(map[string]string)
+			blocks.Records = append(blocks.Records, record)
+			lastKey = ""
+		}
+		if line == "" {
+			if def.Start == nil {
+				record = nil
+			}
+			continue
+		}
+		if record == nil {
+			continue
+		}
+		kv := strings.SplitN(line, def.Sep, 2)
+		if len(kv) != 2 {
+			if lastKey != "" && strings.TrimSpace(line) != line {
+				record[lastKey] = joinValue(record[lastKey], strings.TrimSpace(line))
+			}
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		if key == "" {
+			continue
+		}
+		if !seen[key] {
+			seen[key] = true
+			blocks.Keys = append(blocks.Keys, key)
+		}
+		if existing, ok := record[key]; ok {
+			record[key] = joinValue(existing, strings.TrimSpace(kv[1]))
+		} else {
+			record[key] = strings.TrimSpace(kv[1])
+		}
+		lastKey = key
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf("Unable to read the input. The error is %v", err)
+	}
+	return blocks
+}
+
+func joinValue(existing string, value string) string {
+	if existing == "" {
+		return value
+	}
+	if value == "" {
+		return existing
+	}
+	return existing + "," + value
+}
+
+// processKvBlocks writes a row for each block, the headers are the union of the keys
+func processKvBlocks(csvFmt *CsvFormat, reader io.Reader) {
+	def := csvFmt.KvBlockDef
+	if def == nil {
+		def = &KvBlockDef{Sep: ":"}
+	}
+	blocks := parseKvBlocks(reader, def)
+	processor := NewLineProcessor(csvFmt)
+	defer processor.Close()
+	if !csvFmt.NoHeaderIn {
+		processor.processRow(func() []string { return blocks.Keys })
+	}
+	for _, record := range blocks.Records {
+		processor.processRow(func() []string {
+			row := make([]string, len(blocks.Keys))
+			for i, key := range blocks.Keys {
+				row[i] = record[key]
+			}
+			return row
+		})
+	}
+	processLines(csvFmt, processor)
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+type logfmtPair struct {
+	Key   string
+	Value string
+}
+
+// LogfmtParseLine parses each line of the stdin as logfmt eg. ts=... level=info msg="user login" user=42. The flags
+// are same as the jpl
+func LogfmtParseLine(args []string) {
+	parseLineRecords(args, parseLogfmtBytes)
+}
+
+/*
+	parseLogfmtBytes returns the pairs of the line as a record, nil if the line has no key=value pair. The dotted keys
+	eg. http.status=200 are nested same as the json, so the keys[http.status] and the filter work the same as jpl. A
+	dotted key conflicting with a value of the parent key is skipped
+*/
+func parseLogfmtBytes(line []byte) []map[string]interface{} {
+	pairs := parseLogfmt(string(line))
+	if pairs == nil {
+		return nil
+	}
+	record := make(map[string]interface{})
+	for _, pair := range pairs {
+		segments := splitKey(pair.Key)
+		parent := record
+		for _, segment := range segments[:len(segments)-1] {
+			child, ok := parent[segment].(map[string]interface{})
+			if !ok {
+				if _, exists := parent[segment]; exists {
+					parent = nil
+					break
+				}
+				child = make(map[string]interface{})
+				parent[segment] = child
+			}
+			parent = child
+		}
+		if parent != nil {
+			parent[segments[len(segments)-1]] = pair.Value
+		}
+	}
+	return []map[string]interface{}{record}
+}
+
+/*
+	parseLogfmt splits the line into the key=value pairs. The quoted values are unquoted, the keys without a value are
+	true. nil if the line has no key=value pair or has an unterminated quote
+*/
+func parseLogfmt(line string) []logfmtPair {
+	var pairs []logfmtPair
+	hasValue := false
+	i := 0
+	for i < len(line) {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' && line[i] != '"' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" || (i < len(line) && line[i] == '"') {
+			return nil
+		}
+		if i >= len(line) || line[i] != '=' {
+			pairs = append(pairs, logfmtPair{Key: key, Value: "true"})
+			continue
+		}
+		i++
+		hasValue = true
+		if i < len(line) && line[i] == '"' {
+			value, end := readQuoted(line, i)
+			if end < 0 {
+				return nil
+			}
+			pairs = append(pairs, logfmtPair{Key: key, Value: value})
+			i = end
+			continue
+		}
+		start = i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			i++
+		}
+		pairs = append(pairs, logfmtPair{Key: key, Value: line[start:i]})
+	}
+	if !hasValue {
+		return nil
+	}
+	return pairs
+}
+
+// readQuoted returns the unquoted value of the quoted string at the start and the index after the closing quote.
+// The index is -1 if there is no closing quote
+func readQuoted(line string, start int) (string, int) {
+	for i := start + 1; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == '"' {
+			quoted := line[start : i+1]
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				value = strings.ReplaceAll(quoted[1:len(quoted)-1], `\"`, `"`)
+			}
+			return value, i + 1
+		}
+	}
+	return "", -1
+}
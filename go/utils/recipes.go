@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"github.com/abeytom/utilbox/common"
+	"strings"
+)
+
+/*
+	expandRecipes replaces the @name args with the flags of the recipe saved by
+	bk add recipe lag "row[1:] group[0]:count sort[1]:desc out..table"
+
+	csv @lag
+	csv @lag out..json      => the flags after the recipe are applied later, so those take precedence
+*/
+func expandRecipes(args []string) []string {
+	if !hasRecipeArgs(args) {
+		return args
+	}
+	conf := getConf(getBaseDir())
+	if conf == nil {
+		fatalf("Unable to read the recipes")
+	}
+	return doExpandRecipes(args, conf.Recipes, map[string]bool{})
+}
+
+func hasRecipeArgs(args []string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") && len(arg) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// a recipe can refer to other recipes; the cycles are not allowed
+func doExpandRecipes(args []string, recipes map[string]string, expanding map[string]bool) []string {
+	var nArgs []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") || len(arg) == 1 {
+			nArgs = append(nArgs, arg)
+			continue
+		}
+		name := arg[1:]
+		recipe, exists := recipes[name]
+		if !exists {
+			fatalf("Unknown recipe '%v'. Add it with: bk add recipe %v \"<flags>\"", name, name)
+		}
+		if expanding[name] {
+			fatalf("The recipe '%v' refers to itself", name)
+		}
+		expanding[name] = true
+		nArgs = append(nArgs, doExpandRecipes(common.SplitArgs(recipe), recipes, expanding)...)
+		delete(expanding, name)
+	}
+	return nArgs
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
-	"encoding/csv"
+	"bufio"
+	"errors"
+	"io"
 	"log"
 	"os"
 	"regexp"
@@ -13,62 +15,191 @@ type regexInput struct {
 	regex      []*regexp.Regexp
 	cols       int
 	groupCount int
+	all        bool
+	unmatched  string
+	headers    []string
 }
 
+/*
+	RegexExtract applies the regex on each line and writes the groups as the columns. The names of the groups
+	(?P<name>..) are the headers. The rest of the args are the flags of the csv command eg. group[], sort[], out..table
+*/
 func RegexExtract(args []string) {
-	input := processArgs(args)
-	regexList := input.regex
-	writer := csv.NewWriter(os.Stdout)
-	defer writer.Flush()
-	readStdIn2(func(line []byte) {
-		for _, regex := range regexList {
-			matches := regex.FindSubmatch(line)
-			size := len(matches)
-			if size > 1 {
-				row := make([]string, input.cols)
-				i := 1
-				for ; i < size; i++ {
-					row[i-1] = string(matches[i])
-				}
-				for ; i < input.cols; i++ {
-					row[i-1] = ""
-				}
-				writer.Write(row)
-				break
+	stat, _ := os.Stdin.Stat()
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		log.Fatal(errors.New("there is no data to read from STDIN"))
+		return
+	}
+	defer exitOnError()
+	input, csvArgs := processArgs(args)
+	if input.headers == nil {
+		csvArgs = append([]string{"-inhead"}, csvArgs...)
+	}
+	processRegex(input, parseCsvArgs(csvArgs), os.Stdin)
+}
+
+func processRegex(input regexInput, csvFmt *CsvFormat, reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	processor := NewLineProcessor(csvFmt)
+	defer processor.Close()
+	if input.headers != nil && !csvFmt.NoHeaderIn {
+		processor.processRow(func() []string { return input.headers })
+	}
+	for scanner.Scan() {
+		for _, row := range input.extract(scanner.Text()) {
+			processor.processRow(func() []string { return row })
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf("Unable to read the input. The error is %v", err)
+	}
+	processLines(csvFmt, processor)
+}
+
+// extract returns the rows of the first matching regex, a row for each match in the all mode
+func (input *regexInput) extract(line string) [][]string {
+	var rows [][]string
+	for _, regex := range input.regex {
+		var matches [][]string
+		if input.all {
+			matches = regex.FindAllStringSubmatch(line, -1)
+		} else if match := regex.FindStringSubmatch(line); match != nil {
+			matches = [][]string{match}
+		}
+		for _, match := range matches {
+			row := make([]string, input.cols)
+			copy(row, match[1:])
+			if input.unmatched == "flag" {
+				row = append(row, "true")
 			}
+			rows = append(rows, row)
 		}
-	})
+		if len(rows) > 0 {
+			return rows
+		}
+	}
+	if input.unmatched == "pass" || input.unmatched == "flag" {
+		row := make([]string, input.cols)
+		row[0] = line
+		if input.unmatched == "flag" {
+			row = append(row, "false")
+		}
+		rows = append(rows, row)
+	}
+	return rows
 }
 
-func processArgs(args []string) regexInput {
+// processArgs separates the regex and its options from the flags of the csv command
+func processArgs(args []string) (regexInput, []string) {
 	input := regexInput{}
+	var csvArgs []string
 	for _, arg := range args {
 		if strings.Index(arg, "cols:") == 0 {
 			cols, err := strconv.Atoi(arg[5:])
 			if err != nil {
-				log.Fatalf("The cols val [%v] should be an integer. The error is %v",
+				fatalf("The cols val [%v] should be an integer. The error is %v",
 					arg[5:], err)
 			}
 			input.cols = cols
+		} else if arg == "all" {
+			input.all = true
+		} else if strings.Index(arg, "unmatched:") == 0 {
+			input.unmatched = arg[10:]
+			if input.unmatched != "skip" && input.unmatched != "pass" && input.unmatched != "flag" {
+				fatalf("Invalid unmatched '%v'. The options are skip, pass and flag", input.unmatched)
+			}
+		} else if isCsvFlag(arg) {
+			csvArgs = append(csvArgs, arg)
 		} else {
-			regex := regexp.MustCompile(arg)
+			regex, err := regexp.Compile(arg)
+			if err != nil {
+				fatalf("Invalid regex '%v'. The error is %v", arg, err)
+			}
 			if regex.NumSubexp() < 1 {
-				log.Fatalf("The regex must contain atleast one group enclosed in (..)")
+				fatalf("The regex must contain atleast one group enclosed in (..)")
 			}
 			input.regex = append(input.regex, regex)
 			input.groupCount = MaxInt(input.groupCount, regex.NumSubexp())
 		}
 	}
 	if len(input.regex) == 0 {
-		log.Fatal("The regex expression must be set")
+		fatalf("The regex expression must be set")
 	}
 	if input.cols > 0 && input.cols < input.groupCount {
-		log.Fatal("The cols value must be greater than or equals the number of regex groups")
+		fatalf("The cols value must be greater than or equals the number of regex groups")
 	}
 	if input.cols == 0 {
 		input.cols = input.groupCount
 	}
-	return input
+	input.headers = regexHeaders(input)
+	return input, csvArgs
+}
+
+/*
+	regexHeaders names the columns by the names of the groups, the first regex with a name for the group is used. The
+	unnamed groups are named by the index. nil if none of the groups are named
+*/
+func regexHeaders(input regexInput) []string {
+	headers := make([]string, input.cols)
+	named := false
+	for i := range headers {
+		for _, regex := range input.regex {
+			names := regex.SubexpNames()
+			if i+1 < len(names) && names[i+1] != "" {
+				headers[i] = names[i+1]
+				named = true
+				break
+			}
+		}
+		if headers[i] == "" {
+			headers[i] = strconv.Itoa(i)
+		}
+	}
+	if !named {
+		return nil
+	}
+	if input.unmatched == "flag" {
+		headers = append(headers, "matched")
+	}
+	return headers
+}
+
+/*
+	isCsvFlag checks if the arg is a valid flag of the csv command rather than a regex. A flag which is also a regex
+	with the groups eg. tail:(\d+), split:(\w+) is the regex. calc(..) always has a group, so it is the flag if the
+	expression is valid
+*/
+func isCsvFlag(arg string) bool {
+	if !hasFlagDelim(arg) || catchError(func() { validateFlag(arg) }) != nil {
+		return false
+	}
+	if flagNameRegex.FindString(arg) == "calc" {
+		return catchError(func() { extractCalcDef(arg, &CsvFormat{}) }) == nil
+	}
+	regex, err := regexp.Compile(arg)
+	return err != nil || regex.NumSubexp() == 0
+}
+
+// hasFlagDelim checks if the name of a flag is followed by the same delimiter as the flag eg. sort[..], out..table,
+// limit:10, calc(..)
+func hasFlagDelim(arg string) bool {
+	name := flagNameRegex.FindString(arg)
+	usage := findFlagUsage(name)
+	if usage == nil {
+		return false
+	}
+	rest := arg[len(name):]
+	switch {
+	case rest == "":
+		return !usage.Bracket && !usage.Value
+	case strings.HasPrefix(rest, "["):
+		return strings.Contains(usage.Usage, "[")
+	case strings.HasPrefix(rest, ":"), strings.HasPrefix(rest, ".."):
+		return usage.Value || strings.Contains(usage.Usage, ":")
+	case strings.HasPrefix(rest, "("):
+		return name == "calc"
+	}
+	return false
 }
 
 func MaxInt(x, y int) int {
@@ -1,15 +1,26 @@
 package utils
 
 import (
-	"regexp"
+	"bufio"
+	"io"
 	"strings"
+	"unicode"
 )
 
+const defaultColumnGap = 2
+
 type TabularData struct {
 	Headers []string            `json:"headers"`
 	Rows    []map[string]string `json:"rows"`
 }
 
+// ColumnSpan is the position of a column in an aligned table. The End is -1 for the last column
+type ColumnSpan struct {
+	Name  string
+	Start int
+	End   int
+}
+
 func unscrape(str string) TabularData {
 	var t TabularData
 	if str == "" {
@@ -21,50 +32,24 @@ func unscrape(str string) TabularData {
 		return t
 	}
 
-	pattern := regexp.MustCompile(`\s{3,}`)
-	matches := make([]map[string]interface{}, 0)
+	spans := inferColumnSpans(lines[0], 3)
 	headers := make([]string, 0)
-	wordStart := 0
-	header := lines[0]
-
-	for _, match := range pattern.FindAllStringIndex(header, -1) {
-		sep := header[match[0]:match[1]]
-		wordEnd := match[1] - len(sep)
-		word := header[wordStart:wordEnd]
-		matches = append(matches, map[string]interface{}{
-			"word":  word,
-			"start": wordStart,
-			"end":   match[1],
-		})
-		headers = append(headers, word)
-		wordStart = match[1]
+	for _, span := range spans {
+		headers = append(headers, span.Name)
 	}
 
-	word := header[wordStart:]
-	matches = append(matches, map[string]interface{}{
-		"word":  word,
-		"start": wordStart,
-		"end":   -1,
-	})
-	headers = append(headers, word)
-
 	rows := make([]map[string]string, 0)
 	for i := 1; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "" {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 		row := make(map[string]string)
-		for _, match := range matches {
-			end := match["end"].(int)
-			if end == -1 {
-				end = len(line)
-			}
-			value := strings.TrimSpace(line[match["start"].(int):end])
+		for j, value := range splitColumns(line, spans) {
 			if value == "" {
 				value = "-"
 			}
-			row[match["word"].(string)] = value
+			row[spans[j].Name] = value
 		}
 		rows = append(rows, row)
 	}
@@ -74,3 +59,75 @@ func unscrape(str string) TabularData {
 		Rows:    rows,
 	}
 }
+
+/*
+	inferColumnSpans infers the column boundaries from the header. The columns are separated by at least minGap
+	spaces, so the header names with a single space eg. "NOMINATED NODE" are retained. The positions are in runes
+*/
+func inferColumnSpans(header string, minGap int) []ColumnSpan {
+	if minGap <= 0 {
+		minGap = defaultColumnGap
+	}
+	runes := []rune(strings.TrimRight(header, " \t\r"))
+	var spans []ColumnSpan
+	start := -1
+	gap := 0
+	for i, char := range runes {
+		if unicode.IsSpace(char) {
+			gap++
+			continue
+		}
+		if start == -1 {
+			start = 0 //the leading spaces belong to the first column
+		} else if gap >= minGap {
+			spans = append(spans, ColumnSpan{Name: strings.TrimSpace(string(runes[start:i])), Start: start, End: i})
+			start = i
+		}
+		gap = 0
+	}
+	if start != -1 {
+		spans = append(spans, ColumnSpan{Name: strings.TrimSpace(string(runes[start:])), Start: start, End: -1})
+	}
+	return spans
+}
+
+// splitColumns splits the line based on the column spans. The blank cells are retained as empty values
+func splitColumns(line string, spans []ColumnSpan) []string {
+	runes := []rune(strings.TrimRight(line, "\r"))
+	values := make([]string, len(spans))
+	for i, span := range spans {
+		if span.Start >= len(runes) {
+			continue
+		}
+		end := span.End
+		if end == -1 || end > len(runes) {
+			end = len(runes)
+		}
+		values[i] = strings.TrimSpace(string(runes[span.Start:end]))
+	}
+	return values
+}
+
+/*
+	split:columns
+	split:columns:3 => the min gap between the columns in the header
+*/
+func processColumns(csvFmt *CsvFormat, reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	processor := NewLineProcessor(csvFmt)
+	defer processor.Close()
+	var spans []ColumnSpan
+	for scanner.Scan() {
+		line := scanner.Text()
+		if spans == nil {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			spans = inferColumnSpans(line, csvFmt.MaxSplit)
+		}
+		processor.processRow(func() []string {
+			return splitColumns(line, spans)
+		})
+	}
+	processLines(csvFmt, processor)
+}
@@ -19,6 +19,7 @@ type Conf struct {
 	Aliases      map[string]string `json:"aliases"`
 	AliasIndices map[string]string `json:"aliasIndices"`
 	Tokens       map[string]string `json:"tokens"`
+	Recipes      map[string]string `json:"recipes"`
 }
 
 /**
@@ -34,6 +35,9 @@ bk list path
 bk exec cmdAlias
 bk exec cmdAlias args1 arg2
 bk exec cmdAlias `bk get path pathAlias` eg  [bk exec ll `bk get path go`]
+bk add recipe lag "row[1:] group[0]:count sort[1]:desc out..table"
+bk get recipe lag
+bk list recipe
 . bk path pathAlias
 open `bk get istore` [open in the pathAlias in finder]
 bk pbc path alias | bk pbc cmd alias | bk pbc alias
@@ -78,6 +82,19 @@ func Execute(args []string) {
 			} else {
 				fmt.Printf("ERR:KV_EXISTS [%s=%s]", alias, path)
 			}
+		} else if subCmd == "recipe" {
+			flags := args[4]
+			if conf.Recipes == nil {
+				conf.Recipes = map[string]string{}
+			}
+			recipes := conf.Recipes
+			if recipe, ok := recipes[alias]; !ok || (len(args) >= 6 && args[5] == "-f") {
+				recipes[alias] = flags
+				writeJson(conf, baseDir)
+				fmt.Printf("INFO:RECIPE_ADDED [%s=%s]", alias, flags)
+			} else {
+				fmt.Printf("ERR:RECIPE_EXISTS [%s=%s]", alias, recipe)
+			}
 		}
 	} else if cmd == "get" || cmd == "pbc" {
 		conf := getConf(baseDir)
@@ -93,6 +110,9 @@ func Execute(args []string) {
 		} else if subCmd == "kv" {
 			alias = args[3]
 			mapVals = conf.Tokens
+		} else if subCmd == "recipe" {
+			alias = args[3]
+			mapVals = conf.Recipes
 		} else {
 			alias = args[2]
 			mapVals = mergeMaps(conf.Aliases, conf.Paths)
@@ -117,6 +137,8 @@ func Execute(args []string) {
 			}
 		} else if subCmd == "kv" {
 			mapVals = conf.Tokens
+		} else if subCmd == "recipe" || subCmd == "recipes" {
+			mapVals = conf.Recipes
 		} else { //catch all
 			mapVals = conf.Paths
 		}
@@ -310,6 +332,7 @@ func createConfJson(baseDir string) {
 		Aliases:      map[string]string{},
 		AliasIndices: map[string]string{},
 		Tokens:       map[string]string{},
+		Recipes:      map[string]string{},
 	}
 	writeJson(&conf, baseDir)
 }
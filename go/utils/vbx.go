@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"github.com/abeytom/utilbox/common"
 	"log"
 	"os"
 	"os/exec"
@@ -35,7 +34,7 @@ func VbExec(args []string) {
 
 func vbHelp() {
 	fmt.Println("Available commands are:")
-	fmt.Println("    vbx ls")
+	fmt.Println("    vbx ls [csv flags eg. sort[1] out..md]")
 	fmt.Println("    vbx clone")
 	fmt.Println("    vbx start")
 	fmt.Println("    vbx stop")
@@ -77,27 +76,16 @@ func VbRemove(args []string) {
 }
 
 func VbStatus(args []string) {
+	defer exitOnError()
+	csvFmt := parseListArgs(args[1:])
 	stOut, stErrOut, stErr := ExecuteCommand2("VBoxManage", "list",
 		"vms", "--long")
 	if stErr != nil {
 		fmt.Printf("[ERROR] %v. %v\n", stErrOut, stErr)
 	}
-	lines := strings.Split(stOut, "\n")
-	var list []map[string]string
-	var kvMap map[string]string
-	for _, line := range lines {
-		if strings.Index(line, "Name:") == 0 {
-			kvMap = make(map[string]string)
-			list = append(list, kvMap)
-		}
-		kv := strings.SplitN(line, ":", 2)
-		if len(kv) != 2 {
-			continue
-		}
-		kvMap[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
-	}
+	blocks := parseKvBlocks(strings.NewReader(stOut), &KvBlockDef{Start: regexp.MustCompile("^Name:"), Sep: ":"})
 	var rows []DataRow
-	for i, kv := range list {
+	for i, kv := range blocks.Records {
 		row := DataRow{
 			Cols: []interface{}{
 				i + 1,
@@ -109,17 +97,6 @@ func VbStatus(args []string) {
 		}
 		rows = append(rows, row)
 	}
-	csvFmt := &CsvFormat{
-		ColExt:      &common.IntRange{},
-		RowExt:      &common.IntRange{},
-		Split:       "space+",
-		Merge:       " ",
-		LMerge:      ",",
-		Wrap:        "",
-		IsLMerge:    false,
-		NoHeaderOut: false,
-		OutputDef:   &OutputDef{Type: "table"},
-	}
 	processOutput(csvFmt, &DataRows{
 		DataRows:     rows,
 		Headers:      []string{"SL", "NAME", "MEMORY", "CPU", "STATE"},
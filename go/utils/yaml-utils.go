@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"github.com/abeytom/utilbox/common"
 	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
 	"log"
 	"strings"
 )
@@ -15,49 +17,61 @@ func YamlParse(args []string) {
 	if yamlBytes == nil {
 		log.Fatal(errors.New("there is no data to read from STDIN"))
 	}
+	array, err := parseYamlBytes(yamlBytes)
+	if err != nil {
+		log.Println(err)
+	}
+	defer exitOnError()
+	processYamlArray(parseJsonArgs(args), array)
+}
+
+// ProcessYaml reads the yaml list or map from the reader and writes the output to the Writer of the format
+func ProcessYaml(csvFmt *CsvFormat, reader io.Reader) (err error) {
+	defer recoverError(&err)
+	yamlBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	array, err := parseYamlBytes(yamlBytes)
+	if err != nil {
+		return err
+	}
+	processYamlArray(csvFmt, array)
+	return nil
+}
+
+func parseYamlBytes(yamlBytes []byte) ([]map[interface{}]interface{}, error) {
 	x := bytes.TrimLeft(yamlBytes, " \t\r\n")
 	isArray := len(x) > 0 && x[0] == '-'
 	var array []map[interface{}]interface{}
 	if isArray {
 		err := yaml.Unmarshal(yamlBytes, &array)
 		if err != nil {
-			log.Printf("Error while marshalling YAML into array. The error is [%v]\n", err)
+			return array, fmt.Errorf("Error while marshalling YAML into array. The error is [%v]", err)
 		}
 	} else {
 		var jsonMap map[interface{}]interface{}
 		err := yaml.Unmarshal(yamlBytes, &jsonMap)
+		array = append(array, jsonMap)
 		if err != nil {
-			log.Printf("Error while marshalling YAML into map. The error is [%v]\n", err)
+			return array, fmt.Errorf("Error while marshalling YAML into map. The error is [%v]", err)
 		}
-		array = append(array, jsonMap)
 	}
-	csvFmt := &CsvFormat{
-		ColExt:    &common.IntRange{},
-		RowExt:    &common.IntRange{},
-		Split:     "space+",
-		Merge:     "csv",
-		LMerge:    ",",
-		Wrap:      "",
-		OutputDef: &OutputDef{Type: "table"},
-		IsLMerge:  false,
-	}
-	doParseCsvArgs(args, csvFmt)
-	if csvFmt.KeyDef != nil {
-		if len(csvFmt.KeyDef.Fields) == 0 {
-			keys := getYamlKeys(array)
-			for _, key := range keys {
-				fmt.Printf("%v\n", key.Key)
-			}
-		} else {
-			keys := csvFmt.KeyDef.Fields
-			rows := flattenYaml(array, keys)
-			processOutput(csvFmt, &DataRows{
-				DataRows:     rows,
-				Headers:      keys,
-				GroupByCount: 0,
-				Converted:    false,
-			})
+	return array, nil
+}
+
+func processYamlArray(csvFmt *CsvFormat, array []map[interface{}]interface{}) {
+	if csvFmt.KeyDef == nil {
+		return
+	}
+	if len(csvFmt.KeyDef.Fields) == 0 {
+		keys := getYamlKeys(array)
+		for _, key := range keys {
+			fmt.Fprintf(csvFmt.out(), "%v\n", key.Key)
 		}
+	} else {
+		keys := csvFmt.KeyDef.Fields
+		processFlatRows(csvFmt, keys, flattenYaml(array, keys))
 	}
 }
 